@@ -17,39 +17,60 @@ limitations under the License.
 package k8sconfig
 
 import (
-	"fmt"
 	"os"
-	"os/user"
-	"path/filepath"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// GetConfig returns a rest.Config to be used for kubernetes client creation.
-// It does so in the following order:
-//   1. Use the passed kubeconfig/masterURL.
-//   2. Fallback to the KUBECONFIG environment variable.
-//   3. Fallback to in-cluster config.
-//   4. Fallback to the ~/.kube/config.
-func GetConfig(masterURL, kubeconfig string) (*rest.Config, error) {
-	if kubeconfig == "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
+// GetContextConfig returns the rest.Config of the named kubeconfig context, or
+// of the current one when context is empty. Without a kubeconfig or context it
+// uses the in-cluster config when running in a pod. It also returns the
+// name of the context that was used, which is empty for in-cluster config.
+func GetContextConfig(masterURL, kubeconfig, context string) (*rest.Config, string, error) {
+	if kubeconfig == "" && context == "" && os.Getenv("KUBECONFIG") == "" {
+		if c, err := rest.InClusterConfig(); err == nil {
+			return c, "", nil
+		}
 	}
-	// If we have an explicit indication of where the kubernetes config lives, read that.
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
 	if kubeconfig != "" {
-		return clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+		rules.ExplicitPath = kubeconfig
 	}
-	// If not, try the in-cluster config.
-	if c, err := rest.InClusterConfig(); err == nil {
-		return c, nil
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	overrides.ClusterInfo.Server = masterURL
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	c, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", err
 	}
-	// If no in-cluster config, try the default location in the user's home directory.
-	if usr, err := user.Current(); err == nil {
-		if c, err := clientcmd.BuildConfigFromFlags("", filepath.Join(usr.HomeDir, ".kube", "config")); err == nil {
-			return c, nil
+	if context == "" {
+		raw, err := cc.RawConfig()
+		if err != nil {
+			return nil, "", err
 		}
+		context = raw.CurrentContext
 	}
+	return c, context, nil
+}
 
-	return nil, fmt.Errorf("could not create a valid kubeconfig")
+// GetContextConfigFromBytes is like GetContextConfig but reads the kubeconfig
+// from data, e.g. when it is piped to the process, instead of from a file.
+func GetContextConfigFromBytes(masterURL string, data []byte, context string) (*rest.Config, string, error) {
+	raw, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, "", err
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	overrides.ClusterInfo.Server = masterURL
+	c, err := clientcmd.NewNonInteractiveClientConfig(*raw, context, overrides, nil).ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	if context == "" {
+		context = raw.CurrentContext
+	}
+	return c, context, nil
 }
@@ -0,0 +1,199 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// SetupKlog redirects klog output to w, rendering each entry as a single line
+// in the given format, which is either "logfmt" or "json".
+func SetupKlog(w io.Writer, format string) error {
+	var s *sink
+	switch format {
+	case "logfmt":
+		s = &sink{w: w, mu: &sync.Mutex{}}
+	case "json":
+		s = &sink{w: w, mu: &sync.Mutex{}, json: true}
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	klog.SetLoggerWithOptions(logr.New(s), klog.WriteKlogBuffer(s.writeKlog))
+	return nil
+}
+
+type sink struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	json   bool
+	name   string
+	values []interface{}
+}
+
+func (s *sink) Init(info logr.RuntimeInfo) {}
+
+func (s *sink) Enabled(level int) bool {
+	return true
+}
+
+func (s *sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	kv := []interface{}{"level", "info"}
+	if level > 0 {
+		kv = append(kv, "v", level)
+	}
+	s.write(kv, msg, keysAndValues)
+}
+
+func (s *sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	kv := []interface{}{"level", "error"}
+	if err != nil {
+		kv = append(kv, "err", err)
+	}
+	s.write(kv, msg, keysAndValues)
+}
+
+func (s *sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &c
+}
+
+func (s *sink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		name = c.name + "/" + name
+	}
+	c.name = name
+	return &c
+}
+
+var klogSeverities = map[byte]string{
+	'I': "info",
+	'W': "warning",
+	'E': "error",
+	'F': "fatal",
+}
+
+// writeKlog renders a line produced by klog's unstructured calls (Info, Errorf,
+// etc.), recovering the severity and caller from klog's header:
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+func (s *sink) writeKlog(data []byte) {
+	line := strings.TrimSuffix(string(data), "\n")
+	i := strings.Index(line, "] ")
+	level, ok := "", false
+	if len(line) > 0 {
+		level, ok = klogSeverities[line[0]]
+	}
+	if i < 0 || !ok {
+		s.write([]interface{}{"level", "info"}, line, nil)
+		return
+	}
+	header := strings.Fields(line[:i])
+	kv := []interface{}{"level", level}
+	if len(header) > 0 {
+		kv = append(kv, "caller", header[len(header)-1])
+	}
+	s.write(kv, line[i+2:], nil)
+}
+
+func (s *sink) write(kv []interface{}, msg string, keysAndValues []interface{}) {
+	all := []interface{}{"ts", time.Now().UTC().Format(time.RFC3339Nano)}
+	all = append(all, kv...)
+	if s.name != "" {
+		all = append(all, "logger", s.name)
+	}
+	all = append(all, "msg", msg)
+	all = append(all, s.values...)
+	all = append(all, keysAndValues...)
+
+	var buf bytes.Buffer
+	if s.json {
+		renderJSON(&buf, all)
+	} else {
+		renderLogfmt(&buf, all)
+	}
+	buf.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(buf.Bytes())
+}
+
+func renderLogfmt(buf *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key(kv, i))
+		buf.WriteByte('=')
+		v := fmt.Sprint(value(kv, i+1))
+		if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+			v = strconv.Quote(v)
+		}
+		buf.WriteString(v)
+	}
+}
+
+func renderJSON(buf *bytes.Buffer, kv []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key(kv, i))
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(value(kv, i+1))
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(value(kv, i+1)))
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+}
+
+func key(kv []interface{}, i int) string {
+	if k, ok := kv[i].(string); ok {
+		return k
+	}
+	return fmt.Sprint(kv[i])
+}
+
+func value(kv []interface{}, i int) interface{} {
+	if i >= len(kv) {
+		return "(MISSING)"
+	}
+	switch v := kv[i].(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
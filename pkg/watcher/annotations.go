@@ -0,0 +1,78 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"path"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	excludeNoisyAnnotations = pflag.Bool("exclude-noisy-annotations", true, "Ignore well-known annotations that change on every rollout or reconcile")
+	noisyAnnotations        = pflag.StringSlice("noisy-annotations", nil, "Coma separated list of additional annotation patterns ignored by --exclude-noisy-annotations, e.g. example.com/*")
+
+	defaultNoisyAnnotations = []string{
+		lastAppliedAnnotation,
+		"kubectl.kubernetes.io/restartedAt",
+		"deployment.kubernetes.io/revision",
+		"deployment.kubernetes.io/revision-history",
+		"control-plane.alpha.kubernetes.io/leader",
+		"k8s.v1.cni.cncf.io/network-status",
+		"k8s.v1.cni.cncf.io/networks-status",
+		"cni.projectcalico.org/*",
+	}
+)
+
+// stripNoisyAnnotations removes the annotations matching the noisy patterns
+// from the metadata of o. Annotations of embedded templates are kept, since
+// changing those is what triggers a rollout.
+func stripNoisyAnnotations(o *unstructured.Unstructured) {
+	annotations, ok, _ := unstructured.NestedMap(o.Object, "metadata", "annotations")
+	if !ok {
+		return
+	}
+	stripped := false
+	for k := range annotations {
+		if k == lastAppliedAnnotation && *decodeLastApplied {
+			continue
+		}
+		if matchesAnnotation(k, defaultNoisyAnnotations) || matchesAnnotation(k, *noisyAnnotations) {
+			delete(annotations, k)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(o.Object, "metadata", "annotations")
+		return
+	}
+	unstructured.SetNestedMap(o.Object, annotations, "metadata", "annotations")
+}
+
+func matchesAnnotation(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
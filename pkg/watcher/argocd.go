@@ -0,0 +1,87 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const argoGroup = "argoproj.io"
+
+// summarizeApplication reports the sync and health status transitions of an
+// Argo CD Application along with the revision it is synced to, e.g.
+// "sync OutOfSync -> Synced at 4f1c2ab" and "health Progressing -> Healthy".
+func summarizeApplication(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: sync %s, health %s, revision %s",
+			orNone(appStatus(new, "sync")), orNone(appStatus(new, "health")), orNone(appRevision(new)))
+	case len(new.Object) == 0:
+		return "deleted"
+	}
+	var lines []string
+	revision := appRevision(new)
+	if before, after := appStatus(old, "sync"), appStatus(new, "sync"); before != after {
+		line := fmt.Sprintf("sync %s -> %s", orNone(before), orNone(after))
+		if revision != "" {
+			line += " at " + revision
+		}
+		lines = append(lines, line)
+	} else if before := appRevision(old); before != revision {
+		lines = append(lines, fmt.Sprintf("revision %s -> %s", orNone(before), orNone(revision)))
+	}
+	if before, after := appStatus(old, "health"), appStatus(new, "health"); before != after {
+		line := fmt.Sprintf("health %s -> %s", orNone(before), orNone(after))
+		if msg, _, _ := unstructured.NestedString(new.Object, "status", "health", "message"); msg != "" {
+			line += ": " + msg
+		}
+		lines = append(lines, line)
+	}
+	before, _, _ := unstructured.NestedString(old.Object, "status", "operationState", "phase")
+	after, _, _ := unstructured.NestedString(new.Object, "status", "operationState", "phase")
+	if before != after {
+		line := fmt.Sprintf("operation %s -> %s", orNone(before), orNone(after))
+		if msg, _, _ := unstructured.NestedString(new.Object, "status", "operationState", "message"); msg != "" {
+			line += ": " + msg
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func appStatus(o *unstructured.Unstructured, field string) string {
+	s, _, _ := unstructured.NestedString(o.Object, "status", field, "status")
+	return s
+}
+
+// appRevision returns the revision an Application is synced to, which
+// multi-source ones list per source, with commit hashes shortened.
+func appRevision(o *unstructured.Unstructured) string {
+	revisions, _, _ := unstructured.NestedStringSlice(o.Object, "status", "sync", "revisions")
+	if r, ok, _ := unstructured.NestedString(o.Object, "status", "sync", "revision"); ok {
+		revisions = []string{r}
+	}
+	for i, r := range revisions {
+		if len(r) == 40 && strings.Trim(r, "0123456789abcdef") == "" {
+			revisions[i] = r[:7]
+		}
+	}
+	return strings.Join(revisions, ",")
+}
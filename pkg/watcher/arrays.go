@@ -0,0 +1,336 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff"
+)
+
+var (
+	arrayDiff      = pflag.String("array-diff", "index", "How array elements are matched when diffing: index or key")
+	collapseArrays = pflag.Bool("collapse-scalar-arrays", false, "Show changes of arrays of scalars, e.g. finalizers or IP lists, as the elements removed and added instead of a positional diff")
+	arrayKeys      = pflag.StringSlice("array-key", nil, "Coma separated list of path=field pairs naming the key field of arrays of objects, e.g. spec.template.spec.containers=name (* matches any key). Without one, arrays are keyed by name or type when possible")
+	setPaths       = pflag.StringSlice("set-paths", nil, "Coma separated list of paths of arrays whose order does not matter, e.g. spec.rules.hosts for the hosts of every rule (* matches any key). Their elements are sorted before diffing, so reordering them is not a change")
+
+	arrayKeyRules   []arrayKeyRule
+	setPathRules    [][]string
+	defaultKeyNames = []string{"name", "type"}
+)
+
+type arrayKeyRule struct {
+	path  []string
+	field string
+}
+
+func parseArrayKeys() error {
+	switch *arrayDiff {
+	case "index", "key":
+	default:
+		return fmt.Errorf("unknown array diff %q", *arrayDiff)
+	}
+	for _, k := range *arrayKeys {
+		i := strings.LastIndexByte(k, '=')
+		if i <= 0 || i == len(k)-1 {
+			return fmt.Errorf("invalid array key %q, expected path=field", k)
+		}
+		arrayKeyRules = append(arrayKeyRules, arrayKeyRule{strings.Split(k[:i], "."), k[i+1:]})
+	}
+	for _, p := range *setPaths {
+		setPathRules = append(setPathRules, strings.Split(p, "."))
+	}
+	return nil
+}
+
+// sortSets sorts the arrays in v at --set-paths in place, by the JSON
+// encoding of their elements. Elements of arrays have the path of the array.
+func sortSets(v interface{}, path []string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			sortSets(e, append(path, k))
+		}
+	case []interface{}:
+		for _, e := range v {
+			sortSets(e, path)
+		}
+		for _, rule := range setPathRules {
+			if matchPath(rule, path) {
+				sortElements(v)
+				return
+			}
+		}
+	}
+}
+
+func sortElements(a []interface{}) {
+	keys := make([]string, len(a))
+	for i, e := range a {
+		b, _ := json.Marshal(e)
+		keys[i] = string(b)
+	}
+	sort.Sort(byKeys{a, keys})
+}
+
+// byKeys sorts elements along with their keys.
+type byKeys struct {
+	elements []interface{}
+	keys     []string
+}
+
+func (s byKeys) Len() int           { return len(s.elements) }
+func (s byKeys) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKeys) Swap(i, j int) {
+	s.elements[i], s.elements[j] = s.elements[j], s.elements[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// alignArrays returns new with the elements of keyed arrays reordered to
+// follow their order in old, so that a positional diff matches elements by
+// key. Values are copied rather than modified; changed reports whether
+// anything was reordered.
+func alignArrays(old, new interface{}, path []string) (aligned interface{}, changed bool) {
+	switch n := new.(type) {
+	case map[string]interface{}:
+		o, ok := old.(map[string]interface{})
+		if !ok {
+			return new, false
+		}
+		var out map[string]interface{}
+		for k, v := range n {
+			ov, ok := o[k]
+			if !ok {
+				continue
+			}
+			av, c := alignArrays(ov, v, append(path, k))
+			if !c {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(n))
+				for k, v := range n {
+					out[k] = v
+				}
+			}
+			out[k] = av
+		}
+		if out == nil {
+			return new, false
+		}
+		return out, true
+
+	case []interface{}:
+		o, ok := old.([]interface{})
+		if !ok {
+			return new, false
+		}
+		if field := arrayKeyField(path, o, n); field != "" {
+			return alignByKey(o, n, field, path), true
+		}
+		var out []interface{}
+		for i := 0; i < len(n) && i < len(o); i++ {
+			av, c := alignArrays(o[i], n[i], path)
+			if !c {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}{}, n...)
+			}
+			out[i] = av
+		}
+		if out == nil {
+			return new, false
+		}
+		return out, true
+	}
+	return new, false
+}
+
+func alignByKey(old, new []interface{}, field string, path []string) []interface{} {
+	byKey := make(map[interface{}]int, len(new))
+	for i, v := range new {
+		byKey[v.(map[string]interface{})[field]] = i
+	}
+
+	out := make([]interface{}, 0, len(new))
+	used := make([]bool, len(new))
+	for _, ov := range old {
+		i, ok := byKey[ov.(map[string]interface{})[field]]
+		if !ok {
+			continue
+		}
+		v, _ := alignArrays(ov, new[i], path)
+		out = append(out, v)
+		used[i] = true
+	}
+	for i, v := range new {
+		if !used[i] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// arrayKeyField returns the field identifying elements of the arrays at path,
+// or "" if they should be compared by index.
+func arrayKeyField(path []string, old, new []interface{}) string {
+	if *arrayDiff != "key" {
+		return ""
+	}
+	for _, r := range arrayKeyRules {
+		if matchPath(r.path, path) {
+			if uniqueKeys(old, r.field) && uniqueKeys(new, r.field) {
+				return r.field
+			}
+			return ""
+		}
+	}
+	for _, field := range defaultKeyNames {
+		if uniqueKeys(old, field) && uniqueKeys(new, field) {
+			return field
+		}
+	}
+	return ""
+}
+
+// uniqueKeys reports whether every element of a is an object with a distinct
+// scalar value for field.
+func uniqueKeys(a []interface{}, field string) bool {
+	if len(a) == 0 {
+		return false
+	}
+	seen := make(map[interface{}]bool, len(a))
+	for _, v := range a {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		k, ok := m[field]
+		if !ok {
+			return false
+		}
+		switch k.(type) {
+		case string, int64, float64, bool:
+		default:
+			return false
+		}
+		if seen[k] {
+			return false
+		}
+		seen[k] = true
+	}
+	return true
+}
+
+func matchPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// collapseScalarArrays replaces the deltas of arrays of scalars changed
+// between old and new with a single modification from the elements removed
+// to the elements added. Arrays that were only reordered are left as is.
+func collapseScalarArrays(deltas []gojsondiff.Delta, old, new interface{}) {
+	for i, d := range deltas {
+		var pos gojsondiff.Position
+		var inner []gojsondiff.Delta
+		switch d := d.(type) {
+		case *gojsondiff.Object:
+			pos, inner = d.PostPosition(), d.Deltas
+		case *gojsondiff.Array:
+			pos, inner = d.PostPosition(), d.Deltas
+		default:
+			continue
+		}
+		o, n := valueAt(old, pos), valueAt(new, pos)
+		if _, ok := d.(*gojsondiff.Array); ok {
+			oa, ok1 := o.([]interface{})
+			na, ok2 := n.([]interface{})
+			if ok1 && ok2 && allScalars(oa) && allScalars(na) {
+				removed, added := difference(oa, na), difference(na, oa)
+				if len(removed) != 0 || len(added) != 0 {
+					deltas[i] = gojsondiff.NewModified(pos, removed, added)
+					continue
+				}
+			}
+		}
+		collapseScalarArrays(inner, o, n)
+	}
+}
+
+func valueAt(v interface{}, pos gojsondiff.Position) interface{} {
+	switch pos := pos.(type) {
+	case gojsondiff.Name:
+		if m, ok := v.(map[string]interface{}); ok {
+			return m[string(pos)]
+		}
+	case gojsondiff.Index:
+		if a, ok := v.([]interface{}); ok && int(pos) < len(a) {
+			return a[pos]
+		}
+	}
+	return nil
+}
+
+func allScalars(a []interface{}) bool {
+	for _, v := range a {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// scalarList is printed by the diff formatter on a single line.
+type scalarList []interface{}
+
+func (l scalarList) GoString() string {
+	elems := make([]string, len(l))
+	for i, v := range l {
+		b, _ := json.Marshal(v)
+		elems[i] = string(b)
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// difference returns the elements of a missing from b, in order.
+func difference(a, b []interface{}) scalarList {
+	in := make(map[interface{}]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	out := scalarList{}
+	for _, v := range a {
+		if !in[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
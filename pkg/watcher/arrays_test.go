@@ -0,0 +1,111 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/yudai/gojsondiff"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podWith returns a pod with containers, each given as name and image.
+func podWith(containers ...string) *unstructured.Unstructured {
+	var list []interface{}
+	for i := 0; i+1 < len(containers); i += 2 {
+		list = append(list, map[string]interface{}{"name": containers[i], "image": containers[i+1]})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "pod"},
+		"spec":       map[string]interface{}{"containers": list},
+	}}
+}
+
+func TestArrayDiff(t *testing.T) {
+	defer func(d string) { *arrayDiff = d }(*arrayDiff)
+
+	for _, tt := range []struct {
+		name      string
+		arrayDiff string
+		old, new  *unstructured.Unstructured
+		want      []string
+	}{{
+		name:      "reorder by key",
+		arrayDiff: "key",
+		old:       podWith("a", "a:1", "b", "b:1"),
+		new:       podWith("b", "b:1", "a", "a:1"),
+	}, {
+		name:      "reorder by index",
+		arrayDiff: "index",
+		old:       podWith("a", "a:1", "b", "b:1"),
+		new:       podWith("b", "b:1", "a", "a:1"),
+		want:      []string{".spec.containers[0]"},
+	}, {
+		name:      "reorder and change by key",
+		arrayDiff: "key",
+		old:       podWith("a", "a:1", "b", "b:1"),
+		new:       podWith("b", "b:2", "a", "a:1"),
+		want:      []string{".spec.containers[1].image"},
+	}, {
+		name:      "insert by key",
+		arrayDiff: "key",
+		old:       podWith("a", "a:1", "b", "b:1"),
+		new:       podWith("c", "c:1", "a", "a:1", "b", "b:1"),
+		want:      []string{".spec.containers[2]"},
+	}, {
+		name:      "insert by index",
+		arrayDiff: "index",
+		old:       podWith("a", "a:1", "b", "b:1"),
+		new:       podWith("c", "c:1", "a", "a:1", "b", "b:1"),
+		want:      []string{".spec.containers[0]"},
+	}, {
+		name:      "delete by key",
+		arrayDiff: "key",
+		old:       podWith("a", "a:1", "b", "b:1", "c", "c:1"),
+		new:       podWith("c", "c:1", "a", "a:1"),
+		want:      []string{".spec.containers[1]"},
+	}, {
+		name:      "duplicate keys fall back to index",
+		arrayDiff: "key",
+		old:       podWith("a", "a:1", "a", "a:2"),
+		new:       podWith("a", "a:2", "a", "a:1"),
+		want:      []string{".spec.containers[0]"},
+	}} {
+		*arrayDiff = tt.arrayDiff
+		compared := tt.new.Object
+		if aligned, ok := alignArrays(tt.old.Object, tt.new.Object, nil); ok {
+			compared = aligned.(map[string]interface{})
+		}
+		diff := gojsondiff.New().CompareObjects(tt.old.Object, compared)
+		var got []string
+		for _, p := range changedPaths(diff.Deltas()) {
+			got = append(got, p.String())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: changed paths %q, want %q", tt.name, got, tt.want)
+		}
+		if diff.Modified() != (len(tt.want) != 0) {
+			t.Errorf("%s: modified %v, want %v", tt.name, diff.Modified(), len(tt.want) != 0)
+		}
+	}
+}
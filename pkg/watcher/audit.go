@@ -0,0 +1,97 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+var auditVerbs = map[watch.EventType]string{
+	watch.Added:    "create",
+	watch.Modified: "update",
+	watch.Deleted:  "delete",
+}
+
+// auditRecord is how an event is encoded by the AuditFormatter. Old is null
+// for creations and New for deletions.
+type auditRecord struct {
+	V         int                    `json:"v"`
+	Seq       uint64                 `json:"seq"`
+	Timestamp time.Time              `json:"timestamp"`
+	Cluster   string                 `json:"cluster,omitempty"`
+	GVR       *jsonResource          `json:"gvr,omitempty"`
+	Key       string                 `json:"key"`
+	Verb      string                 `json:"verb"`
+	Old       map[string]interface{} `json:"old"`
+	New       map[string]interface{} `json:"new"`
+}
+
+// AuditFormatter prints every event as a line of JSON holding the complete
+// objects before and after the change.
+type AuditFormatter struct {
+	Indent bool
+}
+
+func (f *AuditFormatter) Preamble() string {
+	return ""
+}
+
+func (f *AuditFormatter) Epilogue() string {
+	return ""
+}
+
+func (f *AuditFormatter) Format(event *Event) string {
+	r := auditRecord{
+		V:         eventSchemaVersion,
+		Seq:       event.Seq,
+		Timestamp: event.Timestamp,
+		Cluster:   event.Cluster,
+		GVR:       jsonResourceOf(event),
+		Key:       event.Name,
+		Verb:      auditVerbs[event.Type],
+	}
+	switch {
+	case event.Type == watch.Deleted:
+		r.Old = event.Object.Object
+	case event.Type == watch.Modified && event.Old != nil && len(event.Old.Object) != 0:
+		r.Old = event.Old.Object
+		fallthrough
+	default:
+		r.New = event.Object.Object
+	}
+	var b []byte
+	var err error
+	if f.Indent {
+		b, err = json.MarshalIndent(r, "", "  ")
+	} else {
+		b, err = json.Marshal(r)
+	}
+	if err != nil {
+		klog.Error("error encoding event: ", err)
+		return ""
+	}
+	return string(b) + "\n"
+}
+
+// Marker prints nothing, audit logs only hold changes.
+func (f *AuditFormatter) Marker(ts time.Time, text string) string {
+	return ""
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var baselineFile = pflag.String("baseline", "", "Diff every listed object against its state in this capture of --dump-initial with -o json or ndjson, printing nothing for those that match it and additions for those missing from it")
+
+type baselineKey struct {
+	cluster, name string
+}
+
+// baselineObjects holds the objects loaded from --baseline, nil without it.
+var baselineObjects map[baselineKey]*unstructured.Unstructured
+
+// loadBaseline reads --baseline: json or ndjson output, of which the events
+// carrying objects are kept, the last one of every object winning.
+func loadBaseline() error {
+	if *baselineFile == "" {
+		return nil
+	}
+	baselineObjects = map[baselineKey]*unstructured.Unstructured{}
+	return readCapture(*baselineFile, func(e *captureEvent) {
+		if e.Object != nil && e.Type != string(watch.Deleted) {
+			baselineObjects[e.key()] = e.Object
+		}
+	})
+}
+
+// A captureEvent is an event read back from json or ndjson output.
+type captureEvent struct {
+	Cluster string
+	Name    string
+	Type    string
+	GVR     *jsonResource
+	Object  *unstructured.Unstructured
+}
+
+func (e *captureEvent) key() baselineKey {
+	return baselineKey{e.Cluster, e.Name}
+}
+
+// readCapture calls each with every event of the json or ndjson output in
+// path, in order.
+func readCapture(path string, each func(*captureEvent)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	dec := json.NewDecoder(r)
+	if b, err := r.Peek(1); err == nil && b[0] == '[' {
+		dec.Token()
+	}
+	for dec.More() {
+		// Objects are decoded as unstructured ones, with integers rather
+		// than floats, so that they compare equal to listed ones.
+		var e struct {
+			Cluster string          `json:"cluster"`
+			Name    string          `json:"name"`
+			Type    string          `json:"type"`
+			GVR     *jsonResource   `json:"gvr"`
+			Object  json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&e); err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		ce := &captureEvent{Cluster: e.Cluster, Name: e.Name, Type: e.Type, GVR: e.GVR}
+		if e.Object != nil {
+			ce.Object = &unstructured.Unstructured{}
+			if err := ce.Object.UnmarshalJSON(e.Object); err != nil {
+				return fmt.Errorf("error reading %s: %s: %v", path, e.Name, err)
+			}
+		}
+		each(ce)
+	}
+	if _, err := dec.Token(); err != nil && err != io.EOF {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return nil
+}
+
+// baselineEvents returns the diffs of the objects in cache from their states
+// in --baseline, sorted by key.
+func baselineEvents(cl *Cluster, cache *objectCache) []*Event {
+	keys := cache.keys()
+	sort.Strings(keys)
+	now := Now()
+	var events []*Event
+	for _, key := range keys {
+		o, ok := cache.get(key)
+		if !ok || excludedName(o.GetName()) || !matchesWhere(o) {
+			continue
+		}
+		eventType := watch.Modified
+		base, ok := baselineObjects[baselineKey{cl.label, key}]
+		if ok {
+			base = prepareObject(base)
+		} else {
+			eventType, base = watch.Added, emptyUnstructured
+		}
+		d := newObjectDiff(base, o)
+		if !d.diff.Modified() || isNoopUpdate(d.paths) {
+			continue
+		}
+		if e := renderEvent(now, key, eventType, o, d, summarizerOf(o)); e != nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
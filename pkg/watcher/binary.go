@@ -0,0 +1,82 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	showBinaryData = pflag.Bool("show-binary-data", false, "Diff the base64 of ConfigMap binaryData and Secret data as is, instead of their sizes and hashes. Secret hashes are keyed by a random key of the run, so they tell changes apart without revealing anything about the data")
+
+	// secretHashKey keys the hashes of Secret data.
+	secretHashKey = newSecretHashKey()
+)
+
+func newSecretHashKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// describeBinaryData replaces the base64 values of the binaryData of the
+// ConfigMap o, or the data of the Secret o, with their decoded size and
+// hash, e.g. "<binary 1024 bytes, sha256 9f86d081884c7d65>". Values that
+// are not base64, such as redacted ones, are left as is.
+func describeBinaryData(o *unstructured.Unstructured) {
+	if o.GetAPIVersion() != "v1" {
+		return
+	}
+	var field, name string
+	var newHash func() hash.Hash
+	switch o.GetKind() {
+	case "ConfigMap":
+		field, name, newHash = "binaryData", "sha256", sha256.New
+	case "Secret":
+		field, name = "data", "hmac-sha256"
+		newHash = func() hash.Hash { return hmac.New(sha256.New, secretHashKey) }
+	default:
+		return
+	}
+	data, ok := o.Object[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range data {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		h := newHash()
+		h.Write(b)
+		data[k] = fmt.Sprintf("<binary %d bytes, %s %x>", len(b), name, h.Sum(nil)[:8])
+	}
+}
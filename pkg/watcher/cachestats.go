@@ -0,0 +1,232 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	printCacheStats = pflag.Duration("print-cache-stats", 0, "Print the number and approximate size of cached objects per resource to stderr at this interval (0 disables)")
+
+	cacheStatsMu sync.Mutex
+	cacheStats   = map[string]*cacheStat{}
+)
+
+type cacheStat struct {
+	objects, bytes atomic.Int64
+}
+
+// An objectCache holds the last seen state of every object of a resource,
+// keyed by getKey. It is only used by the goroutine watching the resource,
+// but its size can be read from others when --print-cache-stats is set.
+type objectCache struct {
+	objects map[string]*unstructured.Unstructured
+	// first holds the first seen state of every object with --diff-against
+	// first, else it is nil.
+	first map[string]*unstructured.Unstructured
+	// idle holds the objects encoded by compact with --low-memory or
+	// --memory-limit, and recent the keys set since it last ran. Both are
+	// nil without them.
+	idle   map[string][]byte
+	recent map[string]bool
+	stat   *cacheStat
+}
+
+func newObjectCache(cl *Cluster, gvr schema.GroupVersionResource) *objectCache {
+	c := &objectCache{objects: map[string]*unstructured.Unstructured{}}
+	if *diffAgainst == "first" {
+		c.first = map[string]*unstructured.Unstructured{}
+	}
+	if *lowMemory || *memoryLimit > 0 {
+		c.idle = map[string][]byte{}
+		c.recent = map[string]bool{}
+	}
+	if *printCacheStats > 0 {
+		c.stat = &cacheStat{}
+		cacheStatsMu.Lock()
+		cacheStats[cursorKey(cl, gvr)] = c.stat
+		cacheStatsMu.Unlock()
+	}
+	return c
+}
+
+func (c *objectCache) get(key string) (*unstructured.Unstructured, bool) {
+	if o, ok := c.objects[key]; ok {
+		return o, true
+	}
+	return c.expand(key)
+}
+
+// baseline returns the state new versions of the object of key are diffed
+// against: the first seen one with --diff-against first, else prev, the last
+// one.
+func (c *objectCache) baseline(key string, prev *unstructured.Unstructured) *unstructured.Unstructured {
+	if o, ok := c.first[key]; ok {
+		return o
+	}
+	return prev
+}
+
+func (c *objectCache) set(key string, o *unstructured.Unstructured) {
+	c.drop(key)
+	c.objects[key] = o
+	if c.recent != nil {
+		c.recent[key] = true
+	}
+	if _, ok := c.first[key]; c.first != nil && !ok {
+		c.first[key] = o
+	}
+	if c.stat != nil {
+		c.stat.objects.Add(1)
+		c.stat.bytes.Add(approxSize(o.Object))
+	}
+}
+
+// remove forgets the object of key, including its first seen state.
+func (c *objectCache) remove(key string) {
+	c.drop(key)
+	delete(c.first, key)
+}
+
+func (c *objectCache) drop(key string) {
+	if data, ok := c.idle[key]; ok {
+		delete(c.idle, key)
+		if c.stat != nil {
+			c.stat.objects.Add(-1)
+			c.stat.bytes.Add(-int64(len(data)))
+		}
+	}
+	o, ok := c.objects[key]
+	if !ok {
+		return
+	}
+	delete(c.objects, key)
+	if c.stat != nil {
+		c.stat.objects.Add(-1)
+		c.stat.bytes.Add(-approxSize(o.Object))
+	}
+}
+
+func (c *objectCache) clear() {
+	for _, key := range c.keys() {
+		c.remove(key)
+	}
+}
+
+func (c *objectCache) len() int {
+	return len(c.objects) + len(c.idle)
+}
+
+func (c *objectCache) keys() []string {
+	keys := make([]string, 0, c.len())
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	for key := range c.idle {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// approxSize estimates the memory held by an unstructured value: the bytes of
+// its strings plus a rough per value overhead for headers and map entries.
+func approxSize(v interface{}) int64 {
+	const overhead = 16
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n := int64(48)
+		for k, e := range v {
+			n += overhead + int64(len(k)) + approxSize(e)
+		}
+		return n
+	case []interface{}:
+		n := int64(24)
+		for _, e := range v {
+			n += overhead + approxSize(e)
+		}
+		return n
+	case string:
+		return int64(len(v))
+	}
+	return 8
+}
+
+func reportCacheStats(w io.Writer, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			writeCacheStats(w)
+		}
+	}
+}
+
+func writeCacheStats(w io.Writer) {
+	cacheStatsMu.Lock()
+	keys := make([]string, 0, len(cacheStats))
+	for k := range cacheStats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	stats := make([]*cacheStat, len(keys))
+	for i, k := range keys {
+		stats[i] = cacheStats[k]
+	}
+	cacheStatsMu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "RESOURCE\tOBJECTS\tSIZE\n")
+	var objects, bytes int64
+	for i, s := range stats {
+		o, b := s.objects.Load(), s.bytes.Load()
+		objects += o
+		bytes += b
+		if o != 0 {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", keys[i], o, formatBytes(b))
+		}
+	}
+	fmt.Fprintf(tw, "total\t%d\t%s\n", objects, formatBytes(bytes))
+	tw.Flush()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+var diffCaptures = pflag.StringSlice("diff-captures", nil, "Compare the final states of the objects in two captures of -o json or ndjson, given as before.ndjson,after.ndjson, printing how every object differs between them and exiting without contacting any cluster")
+
+// A capturedObject is the final state of an object in a capture.
+type capturedObject struct {
+	object   *unstructured.Unstructured
+	resource schema.GroupVersionResource
+}
+
+// validateDiffCaptures checks --diff-captures.
+func validateDiffCaptures() error {
+	if len(*diffCaptures) == 0 {
+		return nil
+	}
+	if len(*diffCaptures) != 2 {
+		return fmt.Errorf("--diff-captures takes two captures, got %d", len(*diffCaptures))
+	}
+	if *baselineFile != "" || *rawOutput != "" {
+		return fmt.Errorf("--diff-captures cannot be used with --baseline or --raw")
+	}
+	return nil
+}
+
+// replayCapture returns the objects left in the capture in path once all of
+// its events are applied in order.
+func replayCapture(path string) (map[baselineKey]capturedObject, error) {
+	objects := map[baselineKey]capturedObject{}
+	found := false
+	err := readCapture(path, func(e *captureEvent) {
+		if e.Type == string(watch.Deleted) {
+			delete(objects, e.key())
+			found = true
+			return
+		}
+		if e.Object == nil {
+			return
+		}
+		var gvr schema.GroupVersionResource
+		if e.GVR != nil {
+			gvr = schema.GroupVersionResource{Group: e.GVR.Group, Version: e.GVR.Version, Resource: e.GVR.Resource}
+		}
+		objects[e.key()] = capturedObject{e.Object, gvr}
+		found = true
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("%s holds no objects, captures must be -o json or ndjson output", path)
+	}
+	return objects, err
+}
+
+// compareCaptures writes to s how the objects differ between the final
+// states of the two --diff-captures, returning the exit code.
+func compareCaptures(s sinks) int {
+	before, err := replayCapture((*diffCaptures)[0])
+	if err != nil {
+		klog.Error(err)
+		return 1
+	}
+	after, err := replayCapture((*diffCaptures)[1])
+	if err != nil {
+		klog.Error(err)
+		return 1
+	}
+	var keys []baselineKey
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	now := Now()
+	counts := map[watch.EventType]int{}
+	s.preamble()
+	for _, k := range keys {
+		a, inBefore := before[k]
+		b, inAfter := after[k]
+		eventType, old, new, c := watch.Modified, emptyUnstructured, emptyUnstructured, b
+		switch {
+		case !inBefore:
+			eventType, new = watch.Added, prepareObject(b.object)
+		case !inAfter:
+			eventType, old, c = watch.Deleted, prepareObject(a.object), a
+		default:
+			old, new = prepareObject(a.object), prepareObject(b.object)
+		}
+		o := c.object
+		if !namespaceFilter(o.GetNamespace()) || excludedName(o.GetName()) || !matchesWhere(o) {
+			continue
+		}
+		d := newObjectDiff(old, new)
+		if !d.diff.Modified() || eventType == watch.Modified && isNoopUpdate(d.paths) {
+			continue
+		}
+		e := renderEvent(now, k.name, eventType, o, d, summarizerOf(o))
+		if e == nil {
+			continue
+		}
+		e.Cluster, e.Resource = k.cluster, c.resource
+		s.event(e)
+		counts[eventType]++
+	}
+	s.marker(now, fmt.Sprintf("%d objects compared: %d added, %d deleted, %d modified", len(keys), counts[watch.Added], counts[watch.Deleted], counts[watch.Modified]))
+	s.epilogue()
+	return 0
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const certManagerGroup = "cert-manager.io"
+
+// summarizeCertificate reports the readiness of a cert-manager Certificate
+// and when it expires and is renewed, e.g.
+// "notAfter 2026-01-10T00:00:00Z -> 2026-04-10T00:00:00Z (expires in 89d)".
+func summarizeCertificate(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		secret, _, _ := unstructured.NestedString(new.Object, "spec", "secretName")
+		line := "created: secret " + secret
+		if names, _, _ := unstructured.NestedStringSlice(new.Object, "spec", "dnsNames"); len(names) > 0 {
+			line += ", dns names " + strings.Join(names, ",")
+		}
+		if notAfter, ok, _ := unstructured.NestedString(new.Object, "status", "notAfter"); ok {
+			line += ", notAfter " + notAfter + expiry(notAfter)
+		}
+		return line
+	case len(new.Object) == 0:
+		return "deleted"
+	}
+	lines := conditionTransitions(old, new)
+	for _, field := range []string{"notAfter", "renewalTime"} {
+		before, _, _ := unstructured.NestedString(old.Object, "status", field)
+		after, _, _ := unstructured.NestedString(new.Object, "status", field)
+		if before == after {
+			continue
+		}
+		line := fmt.Sprintf("%s %s -> %s", field, orNone(before), orNone(after))
+		if field == "notAfter" {
+			line += expiry(after)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// summarizeCertificateRequest reports the condition transitions of a
+// cert-manager CertificateRequest, such as it being approved and issued.
+func summarizeCertificateRequest(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		line := "created"
+		if cert := new.GetAnnotations()[certManagerGroup+"/certificate-name"]; cert != "" {
+			line += " for certificate " + cert
+		}
+		return line
+	case len(new.Object) == 0:
+		return "deleted"
+	}
+	return strings.Join(conditionTransitions(old, new), "\n")
+}
+
+// expiry describes how long until the RFC 3339 time notAfter, e.g.
+// " (expires in 89d)", or nothing if it cannot be parsed.
+func expiry(notAfter string) string {
+	t, err := time.Parse(time.RFC3339, notAfter)
+	if err != nil {
+		return ""
+	}
+	if d := t.Sub(Now()); d > 0 {
+		return " (expires in " + duration.HumanDuration(d) + ")"
+	}
+	return " (expired " + duration.HumanDuration(Now().Sub(t)) + " ago)"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/skaslev/kubectl-watch/pkg/k8sconfig"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// contextLabel is the value of a bare --cluster-label.
+	contextLabel = "<context>"
+	// stdinKubeconfig is the --kubeconfig that is read from stdin.
+	stdinKubeconfig = "-"
+)
+
+var (
+	clusterLabel       = pflag.String("cluster-label", "", "Tag every event with this name, or with the kube context name if given without a value")
+	userAgent          = pflag.String("user-agent", defaultUserAgent(), "User agent sent to the API server, so that cluster admins can tell the requests of this tool apart")
+	disableCompression = pflag.Bool("disable-compression", false, "Don't request gzip compressed responses from the API server. Compression is on by default, which speeds up large lists over slow links at some CPU cost, so disabling it only helps on fast local networks")
+	contentType        = pflag.String("content-type", runtime.ContentTypeJSON, "Content type for listing and watching: "+runtime.ContentTypeJSON+" or "+runtime.ContentTypeProtobuf+". Protobuf only applies to built-in resources, custom and aggregated resources always use JSON")
+
+	// stdinConfig holds the kubeconfig read from stdin for --kubeconfig -.
+	stdinConfig []byte
+)
+
+// defaultUserAgent returns kubectl-watch/<version>, where the version is the
+// one of the module the binary was built from.
+func defaultUserAgent() string {
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	return "kubectl-watch/" + version
+}
+
+// readStdinKubeconfig reads the kubeconfig from stdin if one of kubeconfigs
+// asks for it, so that it is never written to disk.
+func readStdinKubeconfig(kubeconfigs []string) error {
+	for _, k := range kubeconfigs {
+		if k != stdinKubeconfig {
+			continue
+		}
+		if *interactive {
+			return fmt.Errorf("--kubeconfig %s cannot be combined with --interactive, which reads key presses from stdin", stdinKubeconfig)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("error reading kubeconfig from stdin: %v", err)
+		}
+		stdinConfig = data
+		return nil
+	}
+	return nil
+}
+
+func validateContentType() error {
+	switch *contentType {
+	case runtime.ContentTypeJSON, runtime.ContentTypeProtobuf:
+		return nil
+	}
+	return fmt.Errorf("unsupported content type %q", *contentType)
+}
+
+// A Cluster holds the clients used to watch one API server. Events from a
+// cluster are tagged with its label, which is empty when only one cluster is
+// watched and --cluster-label is not given.
+type Cluster struct {
+	name   string
+	label  string
+	cfg    *rest.Config
+	client kubernetes.Interface
+	dc     dynamic.Interface
+	disc   discovery.DiscoveryInterface
+
+	mu       sync.Mutex
+	typed    map[schema.GroupVersion]rest.Interface
+	metadata metadata.Interface
+	kinds    map[schema.GroupVersionResource]string
+	// watches is only set with --discovery-refresh.
+	watches *watchSet
+}
+
+type clusterSpec struct {
+	kubeconfig, context string
+}
+
+// clusterSpecs pairs every given kubeconfig with every given context. An empty
+// field selects the default kubeconfig or its current context.
+func clusterSpecs(kubeconfigs, contexts []string) []clusterSpec {
+	if len(kubeconfigs) == 0 {
+		kubeconfigs = []string{""}
+	}
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	var specs []clusterSpec
+	for _, k := range kubeconfigs {
+		for _, c := range contexts {
+			specs = append(specs, clusterSpec{kubeconfig: k, context: c})
+		}
+	}
+	return specs
+}
+
+func newCluster(spec clusterSpec) (*Cluster, error) {
+	var cfg *rest.Config
+	var name string
+	var err error
+	if spec.kubeconfig == stdinKubeconfig {
+		cfg, name, err = k8sconfig.GetContextConfigFromBytes(*masterURL, stdinConfig, spec.context)
+	} else {
+		cfg, name, err = k8sconfig.GetContextConfig(*masterURL, spec.kubeconfig, spec.context)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error building kubeconfig: %v", err)
+	}
+	if name == "" {
+		name = strings.TrimPrefix(strings.TrimPrefix(cfg.Host, "https://"), "http://")
+	}
+	cfg.QPS = float32(configQPSPerLister * *listConcurrency)
+	cfg.Burst = configBurst
+	if *disableCompression {
+		cfg.DisableCompression = true
+	}
+	if *userAgent != "" {
+		cfg.UserAgent = *userAgent
+	}
+
+	c, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %v", err)
+	}
+
+	dc, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	var disc discovery.DiscoveryInterface = c.Discovery()
+	if *discoveryCacheTTL > 0 {
+		disc, err = newCachedDiscovery(cfg, *discoveryCacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("error creating discovery cache: %v", err)
+		}
+	}
+	return &Cluster{name: name, cfg: cfg, client: c, dc: dc, disc: disc}, nil
+}
+
+// labelClusters tags events with the cluster name when more than one cluster
+// is watched, or as requested by --cluster-label. Clusters sharing a context
+// name across kubeconfigs are told apart by the kubeconfig file name.
+func labelClusters(clusters []*Cluster, specs []clusterSpec) {
+	if len(clusters) < 2 {
+		switch *clusterLabel {
+		case "":
+		case contextLabel:
+			clusters[0].label = clusters[0].name
+		default:
+			clusters[0].label = *clusterLabel
+		}
+		return
+	}
+	count := map[string]int{}
+	for _, c := range clusters {
+		count[c.name]++
+	}
+	for i, c := range clusters {
+		c.label = c.name
+		if count[c.name] > 1 && specs[i].kubeconfig != "" {
+			c.label = filepath.Base(specs[i].kubeconfig) + "/" + c.name
+		}
+	}
+}
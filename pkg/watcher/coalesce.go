@@ -0,0 +1,147 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var coalesceWindow = pflag.Duration("coalesce-window", 0, "Merge the updates of an object within this long of the first one into a single diff from the state before them to the one after")
+
+// A coalescer merges bursts of updates to the same object. It is used by a
+// single goroutine.
+type coalescer struct {
+	window  time.Duration
+	pending map[string]*burst
+	// order lists the bursts by when they started, which is also deadline
+	// order.
+	order []*burst
+}
+
+type burst struct {
+	first, last *Event
+	deadline    time.Time
+	done        bool
+}
+
+// newCoalescer returns nil when --coalesce-window is not set.
+func newCoalescer() *coalescer {
+	if *coalesceWindow <= 0 {
+		return nil
+	}
+	return &coalescer{window: *coalesceWindow, pending: map[string]*burst{}}
+}
+
+// add returns the events to print now in response to e. Other events of an
+// object end its burst, which is printed before them.
+func (c *coalescer) add(e *Event) []*Event {
+	if c == nil {
+		return []*Event{e}
+	}
+	key := e.FullName()
+	b := c.pending[key]
+	switch {
+	case b != nil && e.Type == watch.Modified:
+		b.last = e
+		return nil
+	case e.Type == watch.Modified && e.Old != nil:
+		b = &burst{first: e, last: e, deadline: time.Now().Add(c.window)}
+		c.pending[key] = b
+		c.order = append(c.order, b)
+		return nil
+	case b == nil:
+		return []*Event{e}
+	}
+	b.done = true
+	delete(c.pending, key)
+	if merged := b.merge(); merged != nil {
+		return []*Event{merged, e}
+	}
+	return []*Event{e}
+}
+
+// timer returns a channel that fires when the oldest burst is due, or nil if
+// there is none.
+func (c *coalescer) timer() <-chan time.Time {
+	if c == nil {
+		return nil
+	}
+	c.expire(time.Time{})
+	if len(c.order) == 0 {
+		return nil
+	}
+	return time.After(time.Until(c.order[0].deadline))
+}
+
+// due returns the merged bursts whose window has passed.
+func (c *coalescer) due() []*Event {
+	return c.expire(time.Now())
+}
+
+// flush returns all pending bursts merged.
+func (c *coalescer) flush() []*Event {
+	if c == nil {
+		return nil
+	}
+	return c.expire(time.Now().Add(c.window))
+}
+
+// expire ends the bursts due by now and drops the already ended ones from the
+// front of order.
+func (c *coalescer) expire(now time.Time) []*Event {
+	var events []*Event
+	for len(c.order) > 0 {
+		b := c.order[0]
+		if !b.done {
+			if b.deadline.After(now) {
+				break
+			}
+			if merged := b.merge(); merged != nil {
+				events = append(events, merged)
+			}
+			delete(c.pending, b.first.FullName())
+		}
+		c.order = c.order[1:]
+	}
+	return events
+}
+
+// merge returns the diff from the state before the burst to the one after
+// it, or nil if the updates cancelled out or the merged change is filtered
+// out.
+func (b *burst) merge() *Event {
+	if b.first == b.last {
+		return b.first
+	}
+	d := newObjectDiff(b.first.Old, b.last.Object)
+	if !keepDiff(watch.Modified, b.first.Old, d) {
+		return nil
+	}
+	e := renderEvent(b.last.Timestamp, b.last.Name, watch.Modified, b.last.Object, d, summarizerOf(b.last.Object))
+	if e != nil {
+		e.Cluster = b.last.Cluster
+		e.Resource = b.last.Resource
+		e.ResourceVersion = b.last.ResourceVersion
+		e.Labels = b.last.Labels
+		e.Node = b.last.Node
+	}
+	return e
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestCoalesceFiltersMergedBurst(t *testing.T) {
+	defer func(d time.Duration) { *coalesceWindow = d }(*coalesceWindow)
+	defer func(v bool) { *humanChangesOnly = v }(*humanChangesOnly)
+	if _, err := Configure(); err != nil {
+		t.Fatal(err)
+	}
+	*coalesceWindow = time.Hour
+
+	state := func(value, other string, kubectl, controller int) *unstructured.Unstructured {
+		o := configMap(value)
+		o.Object["data"].(map[string]interface{})["other"] = other
+		o.SetManagedFields([]metav1.ManagedFieldsEntry{
+			managedField("kubectl", metav1.ManagedFieldsOperationUpdate, "", kubectl, `{"f:data":{"f:key":{}}}`),
+			managedField("controller", metav1.ManagedFieldsOperationUpdate, "", controller, `{"f:data":{"f:other":{}}}`),
+		})
+		return o
+	}
+	// kubectl changes key and back, the controller changes other in
+	// between. Only the change by the controller is left once merged.
+	updates := []*unstructured.Unstructured{
+		state("one", "a", 1, 0),
+		state("two", "a", 2, 0),
+		state("two", "b", 2, 3),
+		state("one", "b", 4, 3),
+	}
+
+	for _, tt := range []struct {
+		humanOnly bool
+		want      int
+	}{
+		{false, 1},
+		{true, 0},
+	} {
+		*humanChangesOnly = tt.humanOnly
+		cache := newObjectCache(nil, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+		c := newCoalescer()
+		var events []*Event
+		for i, o := range updates {
+			typ := watch.Modified
+			if i == 0 {
+				typ = watch.Added
+			}
+			if e := processEvent(watch.Event{Type: typ, Object: o.DeepCopy()}, cache); e != nil {
+				events = append(events, c.add(e)...)
+			}
+		}
+		events = append(events, c.flush()...)
+
+		var merged []*Event
+		for _, e := range events {
+			if e.Type == watch.Modified {
+				merged = append(merged, e)
+			}
+		}
+		if len(merged) != tt.want {
+			t.Errorf("--human-changes-only=%v: got %d merged updates, want %d", tt.humanOnly, len(merged), tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff/formatter"
+	"golang.org/x/term"
+)
+
+var (
+	colorSpec = pflag.String("colors", "", "Override the colors of the output as a coma separated list of part=color pairs, e.g. add=green,del=red,mod=yellow,ctx=gray. Parts are add and del (changed lines), ctx (unchanged lines), mod (notes above diffs), alert (notes about failures) and header. Colors can be combined with +, e.g. bold+red, or none")
+
+	noInitialDiffColor = pflag.Bool("no-initial-diff-color", false, "Print events received before the initial sync completed without colors, to tell them apart from live changes")
+	colorBy            = pflag.String("color-by", "none", "Color event headers by a hash of the object key, namespace or kind, so related events stand out, or none. Only applies to terminals unless --color is given")
+
+	// headerPalette holds the colors --color-by picks from.
+	headerPalette = []string{"31", "32", "33", "34", "35", "36", "91", "92", "93", "94", "95", "96"}
+
+	colorCodes = map[string]string{
+		"black":   "30",
+		"red":     "31",
+		"green":   "32",
+		"yellow":  "33",
+		"blue":    "34",
+		"magenta": "35",
+		"cyan":    "36",
+		"white":   "37",
+		"gray":    "90",
+		"bold":    "1",
+		"dim":     "2",
+		"reverse": "7",
+	}
+
+	// noteStyle, alertStyle and headerStyle are the SGR parameters of notes
+	// above diffs, of the ones about failures and of event headers. Empty
+	// means uncolored.
+	noteStyle   = "1;33"
+	alertStyle  = "1;31"
+	headerStyle = ""
+)
+
+// parseColors applies --colors to the styles of the diff formatter and of
+// notes and headers. Output is not colored when NO_COLOR is set, unless
+// --color is given.
+func parseColors() error {
+	if os.Getenv("NO_COLOR") != "" && !pflag.CommandLine.Changed("color") {
+		*colorize = false
+	}
+	switch *colorBy {
+	case "key", "namespace", "kind":
+		if !pflag.CommandLine.Changed("color") && (*outFile != "" || !term.IsTerminal(int(os.Stdout.Fd()))) {
+			*colorBy = "none"
+		}
+	case "none":
+	default:
+		return fmt.Errorf("unknown --color-by %q, expected key, namespace, kind or none", *colorBy)
+	}
+	if *colorSpec == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(*colorSpec, ",") {
+		part, color, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid color %q, expected part=color", pair)
+		}
+		style, err := parseStyle(color)
+		if err != nil {
+			return err
+		}
+		switch part {
+		case "add":
+			formatter.AsciiStyles[formatter.AsciiAdded] = style
+		case "del":
+			formatter.AsciiStyles[formatter.AsciiDeleted] = style
+		case "ctx":
+			formatter.AsciiStyles[formatter.AsciiSame] = style
+		case "mod":
+			noteStyle = style
+		case "alert":
+			alertStyle = style
+		case "header":
+			headerStyle = style
+		default:
+			return fmt.Errorf("unknown color part %q, expected add, del, ctx, mod, alert or header", part)
+		}
+	}
+	// The formatter colors every line with a style, even an empty one.
+	for marker, style := range formatter.AsciiStyles {
+		if style == "" {
+			delete(formatter.AsciiStyles, marker)
+		}
+	}
+	return nil
+}
+
+// parseStyle turns names like bold+red into SGR parameters like "1;31".
+func parseStyle(color string) (string, error) {
+	if color == "none" {
+		return "", nil
+	}
+	var codes []string
+	for _, name := range strings.Split(color, "+") {
+		code, ok := colorCodes[name]
+		if !ok {
+			names := make([]string, 0, len(colorCodes))
+			for n := range colorCodes {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("unknown color %q, expected one of %s or none", name, strings.Join(names, ", "))
+		}
+		codes = append(codes, code)
+	}
+	return strings.Join(codes, ";"), nil
+}
+
+// headerStyleOf returns the style of the header of e, which with --color-by
+// adds a color picked by hashing the chosen attribute of its object. Events
+// without one, such as summaries, get the default style.
+func headerStyleOf(e *Event) string {
+	var attr string
+	switch {
+	case *colorBy == "key":
+		attr = e.FullName()
+	case e.Object == nil:
+		return headerStyle
+	case *colorBy == "namespace":
+		attr = e.Object.GetNamespace()
+	case *colorBy == "kind":
+		attr = e.Object.GetKind()
+	default:
+		return headerStyle
+	}
+	h := fnv.New32a()
+	h.Write([]byte(attr))
+	color := headerPalette[h.Sum32()%uint32(len(headerPalette))]
+	if headerStyle == "" {
+		return color
+	}
+	return headerStyle + ";" + color
+}
+
+// uncolored reports whether e is printed without colors by
+// --no-initial-diff-color.
+func uncolored(e *Event) bool {
+	return *noInitialDiffColor && e.Initial
+}
+
+// stripColors removes the color sequences from text.
+func stripColors(text string) string {
+	return sgrSequence.ReplaceAllString(text, "")
+}
+
+// colorText wraps s in the SGR sequence of style when coloring is enabled.
+func colorText(s, style string) string {
+	if !*colorize || style == "" {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
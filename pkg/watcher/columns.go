@@ -0,0 +1,81 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	columns              = pflag.Bool("columns", false, "Print the namespace, name and kind of objects in aligned columns in event headers")
+	namespaceColumnWidth = pflag.Int("namespace-column-width", 0, "Width of the namespace column of --columns, truncating longer namespaces (0 grows it to the longest one seen)")
+	nameColumnWidth      = pflag.Int("name-column-width", 0, "Width of the name column of --columns, truncating longer names (0 grows it to the longest one seen)")
+	kindColumnWidth      = pflag.Int("kind-column-width", 0, "Width of the kind column of --columns, truncating longer kinds (0 grows it to the longest one seen)")
+)
+
+// A columnLayout aligns the namespace, name and kind of objects in event
+// headers. Columns without a fixed width grow to fit the longest value seen,
+// so they only stay aligned from the longest value on.
+type columnLayout struct {
+	widths [3]int
+	fixed  [3]bool
+}
+
+// newColumnLayout returns nil without --columns.
+func newColumnLayout() *columnLayout {
+	if !*columns {
+		return nil
+	}
+	l := &columnLayout{}
+	for i, w := range []int{*namespaceColumnWidth, *nameColumnWidth, *kindColumnWidth} {
+		if w > 0 {
+			l.widths[i], l.fixed[i] = w, true
+		}
+	}
+	return l
+}
+
+// format returns the columns of the object of key, which getKey made.
+func (l *columnLayout) format(key string) string {
+	name, typ, _ := strings.Cut(key, " ")
+	namespace := ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		namespace, name = name[:i], name[i+1:]
+	}
+	kind := typ[strings.LastIndex(typ, "/")+1:]
+
+	var buf strings.Builder
+	for i, v := range []string{namespace, name, kind} {
+		v = sanitize(v)
+		n := len([]rune(v))
+		switch {
+		case l.fixed[i]:
+			v = truncateMiddle(v, l.widths[i])
+			n = len([]rune(v))
+		case n > l.widths[i]:
+			l.widths[i] = n
+		}
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(v)
+		buf.WriteString(strings.Repeat(" ", l.widths[i]-n))
+	}
+	return strings.TrimRight(buf.String(), " ")
+}
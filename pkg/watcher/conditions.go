@@ -0,0 +1,74 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var highlightConditions = pflag.Bool("highlight-conditions", false, "Summarize status condition transitions above the diff, in red for ones to a bad state such as Ready=False or MemoryPressure=True")
+
+// negativeConditions are the condition types that are bad when True, unlike
+// most, such as Ready, which are bad when not True.
+var negativeConditions = sets.New("Degraded", "Failed", "Failure", "ReplicaFailure", "Stalled")
+
+// badCondition reports whether a condition of type typ with status is in a
+// bad state.
+func badCondition(typ, status string) bool {
+	if negativeConditions.Has(typ) || strings.HasSuffix(typ, "Pressure") || strings.HasSuffix(typ, "Unavailable") {
+		return status == "True"
+	}
+	return status == "False" || status == "Unknown"
+}
+
+// highlightConditionChanges prefixes text with a line per condition
+// transition from old to new, colored with alertStyle if it is to a bad state.
+func highlightConditionChanges(text string, old, new *unstructured.Unstructured) string {
+	before := conditionsByType(old)
+	var buf strings.Builder
+	for _, c := range conditions(new) {
+		typ, _, _ := unstructured.NestedString(c, "type")
+		status, _, _ := unstructured.NestedString(c, "status")
+		prev := "<none>"
+		if p, ok := before[typ]; ok {
+			prev, _, _ = unstructured.NestedString(p, "status")
+		}
+		if prev == status {
+			continue
+		}
+		line := fmt.Sprintf("condition %s %s -> %s", typ, prev, status)
+		if reason, _, _ := unstructured.NestedString(c, "reason"); reason != "" {
+			line += " (" + reason + ")"
+		}
+		style := noteStyle
+		if badCondition(typ, status) {
+			style = alertStyle
+			if msg, _, _ := unstructured.NestedString(c, "message"); msg != "" {
+				line += ": " + msg
+			}
+		}
+		buf.WriteString(colorText(line, style))
+		buf.WriteByte('\n')
+	}
+	return buf.String() + text
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+var configFile = pflag.String("config", "", "YAML file with default filters and redactions (see watchConfig), overridden by the corresponding flags")
+
+// watchConfig is the schema of --config. Every field maps to the flag named
+// in its comment and is used only when that flag is not given.
+type watchConfig struct {
+	// --namespace
+	Namespaces []string `json:"namespaces"`
+	// --group-version
+	GroupVersions []string `json:"groupVersions"`
+	// --group-version-resource
+	GroupVersionResources []string `json:"groupVersionResources"`
+	// --only-kinds
+	Kinds []string `json:"kinds"`
+	// --selector
+	Selector string `json:"selector"`
+	// --field-selector
+	FieldSelector string `json:"fieldSelector"`
+	// --ignore-field
+	IgnoreFields []string `json:"ignoreFields"`
+	// --redact-path
+	RedactPaths []string `json:"redactPaths"`
+	// --redact-regex
+	RedactRegexes []string `json:"redactRegexes"`
+}
+
+// loadConfig reads and validates path and applies it to the flags that were
+// not set on the command line.
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg watchConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	setSlice := func(name string, p *[]string, v []string) {
+		if v != nil && !pflag.CommandLine.Changed(name) {
+			*p = v
+		}
+	}
+	setString := func(name string, p *string, v string) {
+		if v != "" && !pflag.CommandLine.Changed(name) {
+			*p = v
+		}
+	}
+	setSlice("namespace", namespaces, cfg.Namespaces)
+	setSlice("group-version", groupVersions, cfg.GroupVersions)
+	setSlice("group-version-resource", groupVersionResources, cfg.GroupVersionResources)
+	setSlice("only-kinds", onlyKinds, cfg.Kinds)
+	setString("selector", labelSelector, cfg.Selector)
+	setString("field-selector", fieldSelector, cfg.FieldSelector)
+	setSlice("ignore-field", ignoreFields, cfg.IgnoreFields)
+	setSlice("redact-path", redactPaths, cfg.RedactPaths)
+	setSlice("redact-regex", redactRegexes, cfg.RedactRegexes)
+	return nil
+}
+
+func (c *watchConfig) validate() error {
+	for _, gv := range c.GroupVersions {
+		if _, err := schema.ParseGroupVersion(strings.TrimLeft(gv, "!")); err != nil {
+			return fmt.Errorf("groupVersions: %v", err)
+		}
+	}
+	for _, gvr := range c.GroupVersionResources {
+		gvr = strings.TrimLeft(gvr, "!")
+		i := strings.LastIndex(gvr, "/")
+		if i < 0 {
+			// A resource category such as all.
+			continue
+		}
+		if gvr[i+1:] == "" {
+			return fmt.Errorf("groupVersionResources: %q is not of the form group/version/resource", gvr)
+		}
+		if _, err := schema.ParseGroupVersion(gvr[:i]); err != nil {
+			return fmt.Errorf("groupVersionResources: %v", err)
+		}
+	}
+	if _, err := labels.Parse(c.Selector); err != nil {
+		return fmt.Errorf("selector: %v", err)
+	}
+	if _, err := fields.ParseSelector(c.FieldSelector); err != nil {
+		return fmt.Errorf("fieldSelector: %v", err)
+	}
+	for _, p := range append(append([]string{}, c.IgnoreFields...), c.RedactPaths...) {
+		for _, s := range strings.Split(p, ".") {
+			if s == "" {
+				return fmt.Errorf("field path %q has an empty segment", p)
+			}
+		}
+	}
+	for _, expr := range c.RedactRegexes {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("redactRegexes: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var configMapKeys = pflag.StringSlice("configmap-keys", nil, "Coma separated list of the keys of ConfigMaps to diff, ignoring changes to their other data and binaryData keys")
+
+// restrictConfigMapKeys removes the data keys of the ConfigMap o other than
+// the ones of --configmap-keys.
+func restrictConfigMapKeys(o *unstructured.Unstructured) {
+	if len(*configMapKeys) == 0 || o.GetKind() != "ConfigMap" || o.GetAPIVersion() != "v1" {
+		return
+	}
+	keep := map[string]bool{}
+	for _, k := range *configMapKeys {
+		keep[k] = true
+	}
+	for _, field := range []string{"data", "binaryData"} {
+		data, ok := o.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range data {
+			if !keep[k] {
+				delete(data, k)
+			}
+		}
+		if len(data) == 0 {
+			delete(o.Object, field)
+		}
+	}
+}
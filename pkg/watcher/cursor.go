@@ -0,0 +1,140 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var (
+	cursorFile     = pflag.String("cursor-file", "", "Record the last seen resourceVersion of every resource in this file")
+	cursorInterval = pflag.Duration("cursor-interval", 10*time.Second, "How often to write the cursor file")
+	resume         = pflag.Bool("resume", false, "Resume watching from the resourceVersions recorded in --cursor-file")
+
+	watchCursor *cursor
+)
+
+// A cursor tracks the last resourceVersion seen for each watched resource.
+type cursor struct {
+	mu       sync.Mutex
+	versions map[string]string
+	dirty    bool
+}
+
+// loadCursor reads the cursor stored at path. A missing file yields an empty
+// cursor.
+func loadCursor(path string) (*cursor, error) {
+	c := &cursor{versions: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.versions); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func cursorKey(cl *Cluster, gvr schema.GroupVersionResource) string {
+	key := gvr.GroupVersion().String() + "/" + gvr.Resource
+	if cl.label != "" {
+		key = cl.label + "/" + key
+	}
+	return key
+}
+
+func (c *cursor) get(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versions[key]
+}
+
+func (c *cursor) set(key, rv string) {
+	if rv == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions[key] != rv {
+		c.versions[key] = rv
+		c.dirty = true
+	}
+}
+
+// save atomically replaces the file at path if anything changed since the
+// last save.
+func (c *cursor) save(path string) error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(c.versions, "", "  ")
+	c.dirty = false
+	c.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(path, append(data, '\n'))
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *cursor) run(path string, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := c.save(path); err != nil {
+				klog.Error("error writing cursor file: ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/yudai/gojsondiff/formatter"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// DeltaFormatter prints an event per line like ndjson, with the diff as a
+// nested jsondiffpatch delta instead of text: changed values are [old, new],
+// added ones [new], removed ones [old, 0, 0], and arrays are objects keyed
+// by index with "_t": "a".
+type DeltaFormatter struct {
+	JSONFormatter
+}
+
+func (f *DeltaFormatter) Format(event *Event) string {
+	e := jsonEventOf(event)
+	e.Diff = ""
+	var old, new *unstructured.Unstructured
+	switch event.Type {
+	case watch.Added:
+		old, new = emptyUnstructured, event.Object
+	case watch.Modified:
+		old, new = event.Old, event.Object
+	case watch.Deleted:
+		old, new = event.Object, emptyUnstructured
+	}
+	if old != nil && new != nil {
+		delta, err := formatter.NewDeltaFormatter().FormatAsJson(newObjectDiff(old, new).diff)
+		if err != nil {
+			klog.Error("error formatting delta: ", err)
+			return ""
+		}
+		e.Delta = delta
+	}
+	return f.encode(e)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/homedir"
+)
+
+var (
+	discoveryCacheTTL = pflag.Duration("discovery-cache-ttl", 0, "Reuse discovery results cached under ~/.kube/cache/discovery for this long (0 disables)")
+
+	illegalCacheDirChars = regexp.MustCompile(`[^(\w/.)]`)
+)
+
+// newCachedDiscovery returns a discovery client sharing kubectl's on-disk
+// cache layout, so either tool can reuse the other's results.
+func newCachedDiscovery(cfg *rest.Config, ttl time.Duration) (discovery.DiscoveryInterface, error) {
+	cacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache")
+	host := strings.TrimPrefix(strings.TrimPrefix(cfg.Host, "https://"), "http://")
+	discoveryDir := filepath.Join(cacheDir, "discovery", illegalCacheDirChars.ReplaceAllString(host, "_"))
+	return disk.NewCachedDiscoveryClientForConfig(cfg, discoveryDir, filepath.Join(cacheDir, "http"), ttl)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	emitComponent  = "kubectl-watch"
+	emitQPS        = 5
+	emitMessageLen = 1024
+)
+
+var (
+	emitEvents   = pflag.Bool("emit-events", false, "Record every printed change as a Kubernetes Event on the changed object. Changes of Events themselves are never recorded")
+	emitEventsOn = pflag.StringSlice("emit-events-on", nil, "Coma separated list of dotted field paths, only updates changing one of which are recorded by --emit-events, e.g. spec.replicas (* matches any key)")
+
+	// eventEmitters holds the emitter of every cluster by label.
+	eventEmitters map[string]*eventEmitter
+)
+
+// An eventEmitter creates Kubernetes Events for the changes of one cluster
+// from a goroutine of its own, so that printing never waits for the server.
+type eventEmitter struct {
+	client  kubernetes.Interface
+	limiter *rate.Limiter
+	events  chan *corev1.Event
+}
+
+func newEventEmitter(client kubernetes.Interface) *eventEmitter {
+	return &eventEmitter{
+		client:  client,
+		limiter: rate.NewLimiter(emitQPS, emitQPS),
+		events:  make(chan *corev1.Event, 1000),
+	}
+}
+
+func startEventEmitters(clusters []*Cluster, stopCh <-chan struct{}) {
+	eventEmitters = map[string]*eventEmitter{}
+	for _, cl := range clusters {
+		if cl.client == nil {
+			klog.Warningf("cannot emit events to cluster %s without a kubernetes client", cl.name)
+			continue
+		}
+		em := newEventEmitter(cl.client)
+		eventEmitters[cl.label] = em
+		go em.run(stopCh)
+	}
+}
+
+func (em *eventEmitter) emit(e *Event) {
+	if em == nil || isEventKind(e) || !matchesEmitPaths(e) {
+		return
+	}
+	select {
+	case em.events <- newKubernetesEvent(e):
+	default:
+		klog.Warningf("dropping event for %s, the API server is falling behind", e.Name)
+	}
+}
+
+func (em *eventEmitter) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case ev := <-em.events:
+			if err := em.limiter.Wait(context.Background()); err != nil {
+				continue
+			}
+			if _, err := em.client.CoreV1().Events(ev.Namespace).Create(context.Background(), ev, metav1.CreateOptions{}); err != nil {
+				klog.Errorf("error creating event for %s: %v", ev.InvolvedObject.Name, err)
+			}
+		}
+	}
+}
+
+// isEventKind reports whether e is about an Event, which must not be recorded
+// to not feed back into the watch.
+func isEventKind(e *Event) bool {
+	gvk := e.Object.GroupVersionKind()
+	return gvk.Kind == "Event" && (gvk.Group == "" || gvk.Group == "events.k8s.io")
+}
+
+// matchesEmitPaths reports whether e changes one of --emit-events-on.
+// Additions and deletions always match.
+func matchesEmitPaths(e *Event) bool {
+	if len(*emitEventsOn) == 0 || e.Type != watch.Modified {
+		return true
+	}
+	for _, pattern := range *emitEventsOn {
+		segments := strings.Split(pattern, ".")
+		for _, p := range e.Paths {
+			if pathMatches(p, segments) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathMatches reports whether p is below or above the path given by
+// segments, such as when all of spec was replaced.
+func pathMatches(p fieldPath, segments []string) bool {
+	for i := 0; i < len(p) && i < len(segments); i++ {
+		var key string
+		switch e := p[i].(type) {
+		case string:
+			key = e
+		case int:
+			key = strconv.Itoa(e)
+		}
+		if segments[i] != "*" && segments[i] != key {
+			return false
+		}
+	}
+	return true
+}
+
+func newKubernetesEvent(e *Event) *corev1.Event {
+	o := e.Object
+	ns := o.GetNamespace()
+	if ns == "" {
+		// Like the kubelet does for nodes.
+		ns = metav1.NamespaceDefault
+	}
+	reason := "ObjectModified"
+	message := "changed"
+	switch e.Type {
+	case watch.Added:
+		reason, message = "ObjectAdded", "added"
+	case watch.Deleted:
+		reason, message = "ObjectDeleted", "deleted"
+	default:
+		var paths []string
+		for _, p := range e.Paths {
+			paths = append(paths, p.String())
+		}
+		if len(paths) > 0 {
+			message += ": " + strings.Join(paths, ", ")
+		}
+	}
+	if len(message) > emitMessageLen {
+		message = message[:emitMessageLen-3] + "..."
+	}
+	ts := metav1.NewTime(e.Timestamp)
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named like client-go's event recorder names them.
+			Name:      fmt.Sprintf("%v.%x", o.GetName(), time.Now().UnixNano()),
+			Namespace: ns,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      o.GetAPIVersion(),
+			Kind:            o.GetKind(),
+			Namespace:       o.GetNamespace(),
+			Name:            o.GetName(),
+			UID:             o.GetUID(),
+			ResourceVersion: o.GetResourceVersion(),
+		},
+		Reason:         reason,
+		Message:        fmt.Sprintf("%s observed %s", emitComponent, message),
+		Source:         corev1.EventSource{Component: emitComponent},
+		FirstTimestamp: ts,
+		LastTimestamp:  ts,
+		Count:          1,
+		Type:           corev1.EventTypeNormal,
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	discoveryGroup   = "discovery.k8s.io"
+	serviceNameLabel = "kubernetes.io/service-name"
+)
+
+var (
+	watchServicesEndpoints = pflag.Bool("watch-services-endpoints", false, "Watch only Services and EndpointSlices and report the ready endpoints of every Service as its slices change, along with changes to its type, address, selector and ports")
+
+	endpointSliceKind = schema.GroupKind{Group: discoveryGroup, Kind: "EndpointSlice"}
+)
+
+// serviceEndpoints holds the endpoints of every slice seen, by the Service
+// the slice belongs to and the UID of the slice, so that the changes of one
+// slice are reported along with the readiness of the whole Service. Slices
+// of all clusters are watched concurrently, hence the lock.
+var serviceEndpoints = struct {
+	mu     sync.Mutex
+	slices map[string]map[string]map[string]bool
+}{slices: map[string]map[string]map[string]bool{}}
+
+// sliceService returns the name of the Service of an EndpointSlice along
+// with the key its endpoints are tracked under: the UID of the Service owning
+// the slice, which tells apart the Services of the same name in different
+// clusters, else its namespace and name.
+func sliceService(slice *unstructured.Unstructured) (string, string) {
+	name := slice.GetLabels()[serviceNameLabel]
+	for _, ref := range slice.GetOwnerReferences() {
+		if ref.Kind == "Service" && ref.Name == name {
+			return name, string(ref.UID)
+		}
+	}
+	return name, slice.GetNamespace() + "/" + name
+}
+
+// serviceKeys returns the keys the endpoints of svc may be tracked under.
+func serviceKeys(svc *unstructured.Unstructured) []string {
+	return []string{string(svc.GetUID()), svc.GetNamespace() + "/" + svc.GetName()}
+}
+
+// sliceEndpoints returns the readiness of every endpoint of an EndpointSlice
+// by its addresses. Endpoints of unknown readiness count as ready, as the
+// API asks.
+func sliceEndpoints(slice *unstructured.Unstructured) map[string]bool {
+	endpoints := map[string]bool{}
+	list, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+	for _, e := range list {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addrs, _, _ := unstructured.NestedStringSlice(m, "addresses")
+		if len(addrs) == 0 {
+			continue
+		}
+		ready, ok, _ := unstructured.NestedBool(m, "conditions", "ready")
+		endpoints[strings.Join(addrs, ",")] = ready || !ok
+	}
+	return endpoints
+}
+
+// trackEndpointSlice records the endpoints of slice, which is gone if it is
+// empty, and returns the numbers of ready and all endpoints of its Service.
+func trackEndpointSlice(key string, slice *unstructured.Unstructured, gone bool) (int, int) {
+	serviceEndpoints.mu.Lock()
+	defer serviceEndpoints.mu.Unlock()
+	slices := serviceEndpoints.slices[key]
+	if gone {
+		delete(slices, string(slice.GetUID()))
+		if len(slices) == 0 {
+			delete(serviceEndpoints.slices, key)
+		}
+	} else {
+		if slices == nil {
+			slices = map[string]map[string]bool{}
+			serviceEndpoints.slices[key] = slices
+		}
+		slices[string(slice.GetUID())] = sliceEndpoints(slice)
+	}
+	return countEndpoints(slices)
+}
+
+func countEndpoints(slices map[string]map[string]bool) (ready, total int) {
+	for _, endpoints := range slices {
+		for _, r := range endpoints {
+			if r {
+				ready++
+			}
+			total++
+		}
+	}
+	return ready, total
+}
+
+// seedSummaries lets the summarizers correlating objects know of the listed
+// object o, which is cached without being summarized.
+func seedSummaries(o *unstructured.Unstructured) {
+	if _, ok := summarizers[endpointSliceKind]; ok && o.GroupVersionKind().GroupKind() == endpointSliceKind {
+		_, key := sliceService(o)
+		trackEndpointSlice(key, o, false)
+	}
+}
+
+// summarizeEndpointSlice reports the ready endpoints of the Service of an
+// EndpointSlice and how the slice changed them, e.g. "svc web: 3/4 endpoints
+// ready (added 10.0.0.5; 10.0.0.7 not ready)".
+func summarizeEndpointSlice(old, new *unstructured.Unstructured) string {
+	slice := new
+	if len(new.Object) == 0 {
+		slice = old
+	}
+	name, key := sliceService(slice)
+	ready, total := trackEndpointSlice(key, slice, len(new.Object) == 0)
+	before, after := sliceEndpoints(old), sliceEndpoints(new)
+	var added, removed, nowReady, notReady []string
+	for addr, r := range after {
+		was, ok := before[addr]
+		switch {
+		case !ok:
+			added = append(added, addr)
+		case r && !was:
+			nowReady = append(nowReady, addr)
+		case !r && was:
+			notReady = append(notReady, addr)
+		}
+	}
+	for addr := range before {
+		if _, ok := after[addr]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+	var changes []string
+	for _, c := range []struct {
+		format string
+		addrs  []string
+	}{{"added %s", added}, {"removed %s", removed}, {"%s ready", nowReady}, {"%s not ready", notReady}} {
+		if len(c.addrs) != 0 {
+			sort.Strings(c.addrs)
+			changes = append(changes, fmt.Sprintf(c.format, strings.Join(c.addrs, ", ")))
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("svc %s: %d/%d endpoints ready (%s)", name, ready, total, strings.Join(changes, "; "))
+}
+
+// summarizeService reports the creation and deletion of a Service and the
+// changes of its type, address, selector and ports, with the readiness of
+// its endpoints when it is created.
+func summarizeService(old, new *unstructured.Unstructured) string {
+	var lines []string
+	switch {
+	case len(old.Object) == 0:
+		line := "created: " + serviceAddress(new)
+		if ready, total, ok := serviceReadiness(new); ok {
+			line += fmt.Sprintf(", %d/%d endpoints ready", ready, total)
+		}
+		lines = append(lines, line)
+	case len(new.Object) == 0:
+		lines = append(lines, "deleted")
+	default:
+		if before, after := serviceAddress(old), serviceAddress(new); before != after {
+			lines = append(lines, before+" -> "+after)
+		}
+		if before, after := serviceSelector(old), serviceSelector(new); before != after {
+			lines = append(lines, fmt.Sprintf("selector %s -> %s", orNone(before), orNone(after)))
+		}
+	}
+	before, after := servicePorts(old), servicePorts(new)
+	for _, p := range sets.List(after.Difference(before)) {
+		lines = append(lines, "+ port "+p)
+	}
+	for _, p := range sets.List(before.Difference(after)) {
+		lines = append(lines, "- port "+p)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// serviceReadiness returns the numbers of ready and all endpoints of svc,
+// if any of its slices was seen.
+func serviceReadiness(svc *unstructured.Unstructured) (int, int, bool) {
+	serviceEndpoints.mu.Lock()
+	defer serviceEndpoints.mu.Unlock()
+	for _, key := range serviceKeys(svc) {
+		if slices, ok := serviceEndpoints.slices[key]; ok {
+			ready, total := countEndpoints(slices)
+			return ready, total, true
+		}
+	}
+	return 0, 0, false
+}
+
+// serviceAddress describes the type and cluster IP of a Service, e.g.
+// "ClusterIP 10.96.0.10" or "ExternalName example.com".
+func serviceAddress(svc *unstructured.Unstructured) string {
+	typ, _, _ := unstructured.NestedString(svc.Object, "spec", "type")
+	if typ == "" {
+		typ = "ClusterIP"
+	}
+	if typ == "ExternalName" {
+		name, _, _ := unstructured.NestedString(svc.Object, "spec", "externalName")
+		return typ + " " + name
+	}
+	ip, _, _ := unstructured.NestedString(svc.Object, "spec", "clusterIP")
+	return typ + " " + orNone(ip)
+}
+
+func serviceSelector(svc *unstructured.Unstructured) string {
+	selector, _, _ := unstructured.NestedStringMap(svc.Object, "spec", "selector")
+	return labels.Set(selector).String()
+}
+
+// servicePorts describes every port of a Service, e.g. "80/TCP -> 8080".
+func servicePorts(svc *unstructured.Unstructured) sets.Set[string] {
+	set := sets.New[string]()
+	ports, _, _ := unstructured.NestedSlice(svc.Object, "spec", "ports")
+	for _, p := range ports {
+		m, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		port, _, _ := unstructured.NestedInt64(m, "port")
+		protocol, _, _ := unstructured.NestedString(m, "protocol")
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		s := fmt.Sprintf("%d/%s", port, protocol)
+		if name, _, _ := unstructured.NestedString(m, "name"); name != "" {
+			s = name + " " + s
+		}
+		if target, ok := m["targetPort"]; ok {
+			s += fmt.Sprintf(" -> %v", target)
+		}
+		set.Insert(s)
+	}
+	return set
+}
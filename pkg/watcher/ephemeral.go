@@ -0,0 +1,121 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var suppressEphemeral = pflag.Duration("suppress-ephemeral", 0, "Hold back objects added after the initial sync for this long and drop them along with their deletion if they are deleted within it")
+
+// An ephemeralFilter holds back Added events for a window so that objects
+// deleted within it can be dropped altogether. It is used by a single
+// goroutine.
+type ephemeralFilter struct {
+	window  time.Duration
+	pending map[string]*heldEvent
+	// order lists the held events by arrival, which is also deadline order.
+	order []*heldEvent
+}
+
+type heldEvent struct {
+	*Event
+	deadline time.Time
+	released bool
+}
+
+// newEphemeralFilter returns nil when --suppress-ephemeral is not set.
+func newEphemeralFilter() *ephemeralFilter {
+	if *suppressEphemeral <= 0 {
+		return nil
+	}
+	return &ephemeralFilter{window: *suppressEphemeral, pending: map[string]*heldEvent{}}
+}
+
+// add returns the events to print now in response to e.
+func (f *ephemeralFilter) add(e *Event) []*Event {
+	if f == nil {
+		return []*Event{e}
+	}
+	key := e.FullName()
+	h := f.pending[key]
+	switch {
+	case h == nil && e.Type == watch.Added:
+		h = &heldEvent{Event: e, deadline: time.Now().Add(f.window)}
+		f.pending[key] = h
+		f.order = append(f.order, h)
+		return nil
+	case h == nil:
+		return []*Event{e}
+	}
+	h.released = true
+	delete(f.pending, key)
+	if e.Type == watch.Deleted {
+		return nil
+	}
+	return []*Event{h.Event, e}
+}
+
+// timer returns a channel that fires when the oldest held event is due, or nil
+// if none is held.
+func (f *ephemeralFilter) timer() <-chan time.Time {
+	if f == nil {
+		return nil
+	}
+	f.expire(time.Time{})
+	if len(f.order) == 0 {
+		return nil
+	}
+	return time.After(time.Until(f.order[0].deadline))
+}
+
+// due returns the held events whose window has passed, in arrival order.
+func (f *ephemeralFilter) due() []*Event {
+	return f.expire(time.Now())
+}
+
+// flush returns all held events, in arrival order. At shutdown it is called
+// once the events still queued were added, so that objects they delete
+// within the window are dropped then too.
+func (f *ephemeralFilter) flush() []*Event {
+	if f == nil {
+		return nil
+	}
+	return f.expire(time.Now().Add(f.window))
+}
+
+// expire releases the held events due by now and drops the already released
+// ones from the front of order.
+func (f *ephemeralFilter) expire(now time.Time) []*Event {
+	var events []*Event
+	for len(f.order) > 0 {
+		h := f.order[0]
+		if !h.released {
+			if h.deadline.After(now) {
+				break
+			}
+			events = append(events, h.Event)
+			delete(f.pending, h.FullName())
+		}
+		f.order = f.order[1:]
+	}
+	return events
+}
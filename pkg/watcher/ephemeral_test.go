@@ -0,0 +1,64 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// eventNames returns the type and name of each of events.
+func eventNames(events []*Event) []string {
+	var names []string
+	for _, e := range events {
+		names = append(names, string(e.Type)+" "+e.Name)
+	}
+	return names
+}
+
+func TestSuppressEphemeral(t *testing.T) {
+	defer func(d time.Duration) { *suppressEphemeral = d }(*suppressEphemeral)
+	*suppressEphemeral = 50 * time.Millisecond
+
+	for _, tt := range []struct {
+		name string
+		// wait is whether the window passes before the deletion.
+		wait bool
+		want []string
+	}{
+		{"deleted within the window", false, []string{"ADDED b"}},
+		{"deleted after the window", true, []string{"ADDED a", "ADDED b", "DELETED a"}},
+	} {
+		f := newEphemeralFilter()
+		var got []*Event
+		got = append(got, f.add(&Event{Type: watch.Added, Name: "a"})...)
+		got = append(got, f.add(&Event{Type: watch.Added, Name: "b"})...)
+		if tt.wait {
+			<-f.timer()
+			time.Sleep(*suppressEphemeral)
+			got = append(got, f.due()...)
+		}
+		got = append(got, f.add(&Event{Type: watch.Deleted, Name: "a"})...)
+		got = append(got, f.flush()...)
+		if names := eventNames(got); !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type Event struct {
+	Timestamp time.Time
+	Cluster   string
+	Name      string
+	Data      string
+	Type      watch.EventType
+	Object    *unstructured.Unstructured
+	// Old is the previous state of a diffed object, empty for additions.
+	Old   *unstructured.Unstructured
+	Paths []fieldPath
+	// Summary counts the changes of diffed events.
+	Summary *diffSummary
+	// Skipped counts the updates dropped by --sample before this one.
+	Skipped int
+	// Seq numbers the printed events of a run from 1.
+	Seq uint64
+	// ResourceVersion is that of the object with --show-resource-version.
+	ResourceVersion string
+	// Labels are those of the watched object with --show-labels, before
+	// any pruning. Object's labels are used when nil.
+	Labels map[string]string
+	// Node is the spec.nodeName of the watched object with
+	// --output-annotate-node.
+	Node string
+	// Initial is set on events printed before the initial sync completed.
+	Initial bool
+	// Resource is the resource the object was watched as.
+	Resource schema.GroupVersionResource
+}
+
+// FullName is the event name prefixed by its cluster when it has one.
+func (e *Event) FullName() string {
+	if e.Cluster == "" {
+		return e.Name
+	}
+	return "[" + e.Cluster + "] " + e.Name
+}
+
+type EventFormatter interface {
+	Preamble() string
+	Epilogue() string
+	Format(event *Event) string
+	// Marker renders a notice that is not about any object, such as the
+	// end of the initial sync.
+	Marker(ts time.Time, text string) string
+}
+
+type DefaultFormatter struct {
+	MaxWidth            int
+	ShowSeq             bool
+	ShowResourceVersion bool
+	NoColor             bool
+	// Since, unless zero, makes times print as the time elapsed since it.
+	Since time.Time
+	// Columns, if set, aligns the namespace, name and kind of objects.
+	Columns *columnLayout
+	// Labels are the keys of the labels whose values follow the name.
+	Labels []string
+	// ShowNode appends the node of pods to the name.
+	ShowNode bool
+}
+
+func (f *DefaultFormatter) Preamble() string {
+	return ""
+}
+
+func (f *DefaultFormatter) Epilogue() string {
+	return ""
+}
+
+const timeFormat = "2006-01-02 15:04:05.000"
+
+func (f *DefaultFormatter) Format(event *Event) string {
+	data := event.Data
+	if f.plain(event) {
+		data = stripColors(data)
+	}
+	data = sanitizeLines(data)
+	if f.MaxWidth > 0 {
+		data = wrapLines(data, f.MaxWidth)
+	}
+	return f.header(event) + data + "\n"
+}
+
+func (f *DefaultFormatter) header(event *Event) string {
+	prefix := "[" + f.timestamp(event.Timestamp) + "] "
+	if f.ShowSeq {
+		prefix += fmt.Sprintf("#%d ", event.Seq)
+	}
+	name := sanitize(event.FullName())
+	if f.Columns != nil {
+		name = f.Columns.format(event.Name)
+		if event.Cluster != "" {
+			name = "[" + sanitize(event.Cluster) + "] " + name
+		}
+	}
+	if f.MaxWidth > 0 {
+		name = truncateMiddle(name, max(f.MaxWidth-len(prefix), 1))
+	}
+	header := prefix + name
+	if f.ShowResourceVersion && event.ResourceVersion != "" {
+		header += " @" + event.ResourceVersion
+	}
+	if len(f.Labels) > 0 {
+		header += " " + sanitize(labelValues(event, f.Labels))
+	}
+	if f.ShowNode && event.Node != "" {
+		header += " node=" + sanitize(event.Node)
+	}
+	if event.Skipped > 0 {
+		header += fmt.Sprintf(" (%d updates skipped)", event.Skipped)
+	}
+	if f.plain(event) {
+		return header + "\n"
+	}
+	return colorText(header, headerStyleOf(event)) + "\n"
+}
+
+// labelValues renders the given labels of the object of event as key=value
+// pairs, like the columns of kubectl get -L. Missing labels have empty values.
+func labelValues(event *Event, keys []string) string {
+	labels := event.Labels
+	if labels == nil && event.Object != nil {
+		labels = event.Object.GetLabels()
+	}
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// plain reports whether event is printed without colors.
+func (f *DefaultFormatter) plain(event *Event) bool {
+	return f.NoColor || uncolored(event)
+}
+
+func (f *DefaultFormatter) Marker(ts time.Time, text string) string {
+	return fmt.Sprintf("[%s] --- %s ---\n", f.timestamp(ts), text)
+}
+
+// timestamp formats t as the wall clock time or, with Since set, as the time
+// elapsed since then, e.g. +00:01:23.456.
+func (f *DefaultFormatter) timestamp(t time.Time) string {
+	if f.Since.IsZero() {
+		return t.Format(timeFormat)
+	}
+	d, sign := t.Sub(f.Since), "+"
+	if d < 0 {
+		d, sign = -d, "-"
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%s%02d:%02d:%02d.%03d", sign, ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// sgrSequence matches the color sequences the diff formatters emit. Those
+// around multi-line strings span several lines of text.
+var sgrSequence = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// sanitizeLines escapes control characters in text outside of the color
+// sequences added by the diff formatter.
+func sanitizeLines(text string) string {
+	var buf strings.Builder
+	last := 0
+	for _, loc := range sgrSequence.FindAllStringIndex(text, -1) {
+		buf.WriteString(sanitize(text[last:loc[0]]))
+		buf.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	buf.WriteString(sanitize(text[last:]))
+	return buf.String()
+}
+
+// sanitize escapes control characters so that object contents can't inject
+// terminal sequences.
+func sanitize(s string) string {
+	if strings.IndexFunc(s, isUnsafe) < 0 {
+		return s
+	}
+	var buf strings.Builder
+	for _, r := range s {
+		if !isUnsafe(r) {
+			buf.WriteRune(r)
+		} else if r < 0x100 {
+			fmt.Fprintf(&buf, "\\x%02x", r)
+		} else {
+			fmt.Fprintf(&buf, "\\u%04x", r)
+		}
+	}
+	return buf.String()
+}
+
+func isUnsafe(r rune) bool {
+	return r != '\n' && r != '\t' && (unicode.IsControl(r) || r == '\u2028' || r == '\u2029')
+}
+
+type TraceEventFormatter struct {
+	Compact    bool
+	needsComma bool
+}
+
+func (f *TraceEventFormatter) Preamble() string {
+	return "["
+}
+
+func (f *TraceEventFormatter) Epilogue() string {
+	if f.Compact {
+		return "]\n"
+	}
+	return "\n]\n"
+}
+
+func (f *TraceEventFormatter) Format(event *Event) string {
+	comma := ""
+	if f.needsComma {
+		comma = ","
+	}
+	f.needsComma = true
+	ts := float64(event.Timestamp.UnixNano()) / 1000
+	args := traceArgsOf(event)
+	if f.Compact {
+		return fmt.Sprintf(`%s{"ts":%f,"name":%s,"ph":"i","pid":1,"tid":1,"s":"t","args":%s}`,
+			comma, ts, jsonString(event.FullName()), args)
+	}
+	return fmt.Sprintf(`%s
+{"ts": %f, "name": %s, "ph": "i", "pid": 1, "tid": 1, "s": "t", "args": %s}`,
+		comma, ts, jsonString(event.FullName()), args)
+}
+
+// traceArgs are the args of trace events, separate keys so that trace
+// viewers can filter and group events by them.
+type traceArgs struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Verb      string `json:"verb,omitempty"`
+	Paths     int    `json:"paths"`
+	Diff      string `json:"diff"`
+}
+
+func traceArgsOf(event *Event) string {
+	args := traceArgs{
+		Cluster: event.Cluster,
+		Verb:    auditVerbs[event.Type],
+		Paths:   len(event.Paths),
+		Diff:    stripColors(event.Data),
+	}
+	if event.Object != nil {
+		args.Namespace, args.Kind = event.Object.GetNamespace(), event.Object.GetKind()
+	}
+	b, _ := json.Marshal(args)
+	return string(b)
+}
+
+func (f *TraceEventFormatter) Marker(t time.Time, text string) string {
+	comma := ""
+	if f.needsComma {
+		comma = ","
+	}
+	f.needsComma = true
+	ts := float64(t.UnixNano()) / 1000
+	if f.Compact {
+		return fmt.Sprintf(`%s{"ts":%f,"name":%s,"ph":"i","pid":1,"tid":1,"s":"g"}`, comma, ts, jsonString(text))
+	}
+	return fmt.Sprintf(`%s
+{"ts": %f, "name": %s, "ph": "i", "pid": 1, "tid": 1, "s": "g"}`, comma, ts, jsonString(text))
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yudai/gojsondiff/formatter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func configMap(value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config"},
+		"data":       map[string]interface{}{"key": value},
+	}}
+}
+
+func TestMultiLineColoredValue(t *testing.T) {
+	defer func(c bool) { *colorize = c }(*colorize)
+	*colorize = true
+
+	namespaceFilter = NewFilter(nil)
+	cache := newObjectCache(nil, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+	processEvent(watch.Event{Type: watch.Added, Object: configMap("one")}, cache)
+	e := processEvent(watch.Event{Type: watch.Modified, Object: configMap("one\ntwo\x07\nthree")}, cache)
+	if e == nil {
+		t.Fatal("no event for a changed value")
+	}
+	added := "\x1b[" + formatter.AsciiStyles[formatter.AsciiAdded] + "m"
+	if !strings.Contains(e.Data, added+"+") || !strings.Contains(e.Data, "three\"\x1b[0m") {
+		t.Fatalf("expected the added value to be colored across its lines, got %q", e.Data)
+	}
+
+	out := (&DefaultFormatter{}).Format(e)
+	if !strings.Contains(out, "two\\x07\n") {
+		t.Errorf("expected the control character to be escaped, got %q", out)
+	}
+	if strings.Contains(out, "\\x1b") {
+		t.Errorf("expected the color sequences to be kept, got %q", out)
+	}
+	if strings.Count(out, "\x1b[") != strings.Count(e.Data, "\x1b[") {
+		t.Errorf("expected every color sequence of %q to be kept, got %q", e.Data, out)
+	}
+
+	for _, s := range []string{stripColors(e.Data), (&DefaultFormatter{MaxWidth: 12}).Format(&Event{Name: "config", Data: stripColors(e.Data)})} {
+		if strings.Contains(s, "\x1b") {
+			t.Errorf("expected no color sequences, got %q", s)
+		}
+	}
+}
+
+func TestWrapMultiLineColoredValue(t *testing.T) {
+	const added, reset = "\x1b[30;42m", "\x1b[0m"
+	text := added + "+  \"key\": \"one\n0123456789abcdef\nthree\"" + reset
+	got := wrapLines(text, 10)
+	want := added + "+  \"key\": " + "\n" +
+		added + "+    \"one" + "\n" +
+		added + "0123456789" + "\n" +
+		added + "  abcdef" + "\n" +
+		"three\"" + reset
+	if got != want {
+		t.Errorf("wrapLines(%q) = %q, want %q", text, got, want)
+	}
+
+	text = stripColors(text)
+	got = wrapLines(text, 10)
+	want = "+  \"key\": " + "\n" +
+		"+    \"one" + "\n" +
+		"0123456789" + "\n" +
+		"  abcdef" + "\n" +
+		"three\""
+	if got != want {
+		t.Errorf("wrapLines(%q) = %q, want %q", text, got, want)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultExcludes are resources that change all the time without anyone
+// editing them, and drown everything else out when watching the whole
+// cluster. A resource without a name stands for its whole group.
+var defaultExcludes = []schema.GroupResource{
+	{Group: "metrics.k8s.io"},
+	{Resource: "events"},
+	{Group: "events.k8s.io", Resource: "events"},
+	{Resource: "endpoints"},
+	{Group: discoveryGroup, Resource: "endpointslices"},
+	{Group: "coordination.k8s.io", Resource: "leases"},
+}
+
+var noDefaultExcludes = pflag.Bool("no-default-excludes", false, "Also watch the resources skipped unless named by -r, -g, --group or --only-kinds: "+describeExcludes())
+
+func describeExcludes() string {
+	names := make([]string, len(defaultExcludes))
+	for i, gr := range defaultExcludes {
+		switch {
+		case gr.Resource == "":
+			names[i] = "all of " + gr.Group
+		case gr.Group == "":
+			names[i] = gr.Resource
+		default:
+			names[i] = gr.String()
+		}
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// defaultExcluded reports whether r of gv is one of the defaultExcludes and
+// was not asked for explicitly.
+func defaultExcluded(gv schema.GroupVersion, r metav1.APIResource) bool {
+	if *noDefaultExcludes {
+		return false
+	}
+	excluded := false
+	for _, gr := range defaultExcludes {
+		if gr.Group == gv.Group && (gr.Resource == "" || gr.Resource == r.Name) {
+			excluded = true
+			break
+		}
+	}
+	if !excluded {
+		return false
+	}
+	group := gv.Group
+	if group == "" {
+		group = "core"
+	}
+	return !named(*groupVersionResources, gv.String()+"/"+r.Name) &&
+		!named(*groupVersions, gv.String()) &&
+		!named(*apiGroups, group) &&
+		!named(*onlyKinds, r.Kind) &&
+		!focusResources[schema.GroupResource{Group: gv.Group, Resource: r.Name}]
+}
+
+// named reports whether names include name, case insensitively, other than
+// as an exclusion.
+func named(names []string, name string) bool {
+	for _, n := range names {
+		count := countPrefix(n, '!')
+		if count%2 == 0 && strings.EqualFold(n[count:], name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var (
+	execCommand     = pflag.String("exec", "", "Run this shell command for every event with the event, formatted by --exec-format, on its stdin and KW_KEY, KW_VERB, KW_CLUSTER, KW_KIND, KW_NAMESPACE and KW_NAME set")
+	execFormat      = pflag.String("exec-format", "ndjson", "Output format of the events passed to --exec")
+	execConcurrency = pflag.Int("exec-concurrency", 4, "Maximum number of --exec commands run at once")
+
+	eventCommand *commandRunner
+)
+
+// A commandRunner runs a command per event from a bounded pool of
+// goroutines. Events are dropped while all of them are busy and the queue is
+// full.
+type commandRunner struct {
+	command   string
+	formatter EventFormatter
+	jobs      chan commandJob
+	done      sync.WaitGroup
+}
+
+type commandJob struct {
+	key   string
+	input string
+	env   []string
+}
+
+func newCommandRunner(command string, formatter EventFormatter, concurrency int) *commandRunner {
+	r := &commandRunner{
+		command:   command,
+		formatter: formatter,
+		jobs:      make(chan commandJob, 100),
+	}
+	r.done.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go r.work()
+	}
+	return r
+}
+
+func (r *commandRunner) run(e *Event) {
+	input := r.formatter.Format(e)
+	if input == "" {
+		return
+	}
+	o := e.Object
+	job := commandJob{
+		key:   e.FullName(),
+		input: input,
+		env: []string{
+			"KW_KEY=" + e.Name,
+			"KW_VERB=" + auditVerbs[e.Type],
+			"KW_CLUSTER=" + e.Cluster,
+			"KW_KIND=" + o.GetKind(),
+			"KW_NAMESPACE=" + o.GetNamespace(),
+			"KW_NAME=" + o.GetName(),
+		},
+	}
+	select {
+	case r.jobs <- job:
+	default:
+		klog.Warningf("dropping event of %s, --exec is falling behind", job.key)
+	}
+}
+
+// wait waits for the queued commands to finish.
+func (r *commandRunner) wait() {
+	close(r.jobs)
+	r.done.Wait()
+}
+
+func (r *commandRunner) work() {
+	defer r.done.Done()
+	for job := range r.jobs {
+		cmd := exec.Command("sh", "-c", r.command)
+		cmd.Stdin = strings.NewReader(job.input)
+		cmd.Env = append(os.Environ(), job.env...)
+		// Keep the output of commands apart from the events on stdout.
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			klog.Errorf("command for %s failed: %v", job.key, err)
+		}
+	}
+}
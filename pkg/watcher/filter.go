@@ -14,9 +14,12 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package main
+package watcher
 
 import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -43,6 +46,42 @@ func NewFilter(names []string) func(string) bool {
 	}
 }
 
+// NewResourceFilter is like NewFilter for group/version/resource names, but
+// names without a slash select the resources in that category, e.g. all.
+func NewResourceFilter(names []string) func(gv string, r metav1.APIResource) bool {
+	var gvrs, categories []string
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			gvrs = append(gvrs, name)
+		} else {
+			categories = append(categories, name)
+		}
+	}
+	if len(categories) == 0 {
+		gvrFilter := NewFilter(gvrs)
+		return func(gv string, r metav1.APIResource) bool {
+			return gvrFilter(gv + "/" + r.Name)
+		}
+	}
+
+	include, exclude := sets.String{}, sets.String{}
+	for _, name := range names {
+		count := countPrefix(name, '!')
+		if count%2 == 0 {
+			include.Insert(name[count:])
+		} else {
+			exclude.Insert(name[count:])
+		}
+	}
+	return func(gv string, r metav1.APIResource) bool {
+		keys := append([]string{gv + "/" + r.Name}, r.Categories...)
+		if include.Len() != 0 && !include.HasAny(keys...) {
+			return false
+		}
+		return !exclude.HasAny(keys...)
+	}
+}
+
 func countPrefix(name string, ch byte) int {
 	i := 0
 	for ; i < len(name); i++ {
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var watchFinalizers = pflag.Bool("watch-finalizer-removals", false, "Only report changes to the finalizers of objects, and whether they are being deleted, to follow stuck deletions")
+
+// summarizeFinalizers reports the finalizers added to and removed from an
+// object, e.g. "- finalizer kubernetes.io/pvc-protection" followed by
+// "deleting for 5m". Objects are only reported as created or deleted when
+// they have finalizers or are being deleted.
+func summarizeFinalizers(old, new *unstructured.Unstructured) string {
+	var lines []string
+	switch {
+	case len(old.Object) == 0:
+		if len(new.GetFinalizers()) == 0 {
+			return ""
+		}
+		lines = append(lines, "created")
+	case len(new.Object) == 0:
+		if len(old.GetFinalizers()) == 0 && old.GetDeletionTimestamp() == nil {
+			return ""
+		}
+		return "deleted"
+	}
+	before, after := sets.New(old.GetFinalizers()...), sets.New(new.GetFinalizers()...)
+	for _, f := range sets.List(after.Difference(before)) {
+		lines = append(lines, "+ finalizer "+f)
+	}
+	for _, f := range sets.List(before.Difference(after)) {
+		lines = append(lines, "- finalizer "+f)
+	}
+	deleting := new.GetDeletionTimestamp()
+	if len(lines) == 0 && (deleting == nil || old.GetDeletionTimestamp() != nil) {
+		return ""
+	}
+	if deleting != nil {
+		line := "deleting for " + duration.HumanDuration(Now().Sub(deleting.Time))
+		if after.Len() > 0 {
+			line += ", waiting for " + strings.Join(sets.List(after), ", ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
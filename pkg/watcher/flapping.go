@@ -0,0 +1,148 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var (
+	detectFlapping = pflag.Bool("detect-flapping", false, "Warn about fields flipping back and forth between two values, as when controllers fight over them, and drop the updates only flipping fields already warned about")
+	flapThreshold  = pflag.Int("flap-threshold", 3, "Number of reverts to a field's previous value within --flap-window after which --detect-flapping warns")
+	flapWindow     = pflag.Duration("flap-window", time.Minute, "Window over which --detect-flapping counts reverts")
+)
+
+// A flapDetector tracks the values every changed field of every object went
+// through. It is used by a single goroutine.
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+	fields    map[string]*fieldFlaps
+	swept     time.Time
+}
+
+type fieldFlaps struct {
+	// from and to are the JSON encoded values of the last change.
+	from, to string
+	// reverts holds the times of the changes back to the previous value
+	// within the window.
+	reverts []time.Time
+	warned  bool
+	last    time.Time
+}
+
+// newFlapDetector returns nil without --detect-flapping.
+func newFlapDetector() *flapDetector {
+	if !*detectFlapping {
+		return nil
+	}
+	return &flapDetector{threshold: max(*flapThreshold, 1), window: *flapWindow, fields: map[string]*fieldFlaps{}}
+}
+
+// add returns the events to print in response to e, e itself unless only
+// fields already warned about flap, and a warning for every field starting to
+// flap, to print as markers ahead of them.
+func (d *flapDetector) add(e *Event) ([]*Event, []string) {
+	if d == nil {
+		return []*Event{e}, nil
+	}
+	prefix := e.FullName() + " "
+	if e.Type == watch.Deleted {
+		for k := range d.fields {
+			if strings.HasPrefix(k, prefix) {
+				delete(d.fields, k)
+			}
+		}
+		return []*Event{e}, nil
+	}
+	if e.Type != watch.Modified || e.Old == nil || e.Object == nil {
+		return []*Event{e}, nil
+	}
+
+	d.sweep(e.Timestamp)
+	var warnings []string
+	flapping := 0
+	changed := 0
+	for _, p := range e.Paths {
+		if isBookkeepingPath(p) {
+			continue
+		}
+		changed++
+		from, to := valueString(p, e.Old.Object), valueString(p, e.Object.Object)
+		key := prefix + p.String()
+		f := d.fields[key]
+		if f == nil {
+			f = &fieldFlaps{}
+			d.fields[key] = f
+		}
+		if from == f.to && to == f.from {
+			f.reverts = append(f.reverts, e.Timestamp)
+		}
+		f.from, f.to, f.last = from, to, e.Timestamp
+		for len(f.reverts) > 0 && e.Timestamp.Sub(f.reverts[0]) > d.window {
+			f.reverts = f.reverts[1:]
+		}
+		switch {
+		case len(f.reverts) >= d.threshold && !f.warned:
+			f.warned = true
+			warnings = append(warnings, fmt.Sprintf("%s %s flapping between %s and %s, %d reverts within %v", e.FullName(), p, from, to, len(f.reverts), d.window))
+		case len(f.reverts) == 0:
+			f.warned = false
+		}
+		if f.warned {
+			flapping++
+		}
+	}
+	if changed > 0 && flapping == changed {
+		return nil, warnings
+	}
+	return []*Event{e}, warnings
+}
+
+// sweep forgets the fields that did not change within the window, at most
+// once per window.
+func (d *flapDetector) sweep(now time.Time) {
+	if now.Sub(d.swept) < d.window {
+		return
+	}
+	for k, f := range d.fields {
+		if now.Sub(f.last) > d.window {
+			delete(d.fields, k)
+		}
+	}
+	d.swept = now
+}
+
+// valueString returns the JSON encoding of the value at p in obj, or <none>.
+func valueString(p fieldPath, obj interface{}) string {
+	v, ok := p.valueIn(obj)
+	if !ok {
+		return "<none>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
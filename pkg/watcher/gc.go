@@ -0,0 +1,190 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var (
+	traceGC       = pflag.Bool("trace-gc", false, "Print the deletion of an object together with those of its dependents that follow as one cascade, e.g. Deployment/foo deleted -> ReplicaSet/foo-abc -> 3 Pods")
+	traceGCWindow = pflag.Duration("trace-gc-window", 5*time.Second, "How long after the last deletion of a cascade --trace-gc waits for further dependents")
+)
+
+// A gcTracer holds back deletions for a window to group the deletions of
+// dependents, found by their ownerReferences, with that of their owner. It
+// is used by a single goroutine.
+type gcTracer struct {
+	window time.Duration
+	// deleted maps the UIDs of the objects deleted by pending cascades to
+	// their deletion.
+	deleted map[types.UID]*gcNode
+	// pending lists the cascades by when they started. Deadlines move as
+	// dependents come in, so it is not in deadline order.
+	pending []*cascade
+}
+
+type cascade struct {
+	nodes    []*gcNode
+	deadline time.Time
+}
+
+type gcNode struct {
+	*Event
+	cascade *cascade
+	// depth is 0 for the object whose deletion started the cascade.
+	depth int
+}
+
+// newGCTracer returns nil when --trace-gc is not set.
+func newGCTracer() *gcTracer {
+	if !*traceGC {
+		return nil
+	}
+	return &gcTracer{window: *traceGCWindow, deleted: map[types.UID]*gcNode{}}
+}
+
+// add returns the events to print now in response to e.
+func (t *gcTracer) add(e *Event) []*Event {
+	if t == nil || e.Type != watch.Deleted || e.Object == nil {
+		return []*Event{e}
+	}
+	var owner *gcNode
+	for _, ref := range e.Object.GetOwnerReferences() {
+		if owner = t.deleted[ref.UID]; owner != nil {
+			break
+		}
+	}
+	n := &gcNode{Event: e}
+	if owner == nil {
+		n.cascade = &cascade{}
+		t.pending = append(t.pending, n.cascade)
+	} else {
+		n.cascade, n.depth = owner.cascade, owner.depth+1
+	}
+	n.cascade.nodes = append(n.cascade.nodes, n)
+	n.cascade.deadline = time.Now().Add(t.window)
+	if uid := e.Object.GetUID(); uid != "" {
+		t.deleted[uid] = n
+	}
+	return nil
+}
+
+// timer returns a channel that fires when the next cascade is due, or nil if
+// there is none.
+func (t *gcTracer) timer() <-chan time.Time {
+	if t == nil || len(t.pending) == 0 {
+		return nil
+	}
+	next := t.pending[0].deadline
+	for _, c := range t.pending[1:] {
+		if c.deadline.Before(next) {
+			next = c.deadline
+		}
+	}
+	return time.After(time.Until(next))
+}
+
+// due returns the cascades whose window has passed.
+func (t *gcTracer) due() []*Event {
+	return t.expire(time.Now())
+}
+
+// flush returns all pending cascades.
+func (t *gcTracer) flush() []*Event {
+	if t == nil {
+		return nil
+	}
+	return t.expire(time.Now().Add(t.window))
+}
+
+// expire ends the cascades due by now. Deletions without dependents are
+// returned as they came.
+func (t *gcTracer) expire(now time.Time) []*Event {
+	var events []*Event
+	pending := t.pending[:0]
+	for _, c := range t.pending {
+		if c.deadline.After(now) {
+			pending = append(pending, c)
+			continue
+		}
+		for _, n := range c.nodes {
+			delete(t.deleted, n.Object.GetUID())
+		}
+		root := c.nodes[0].Event
+		if len(c.nodes) > 1 {
+			e := *root
+			e.Data = c.String()
+			root = &e
+		}
+		events = append(events, root)
+	}
+	t.pending = pending
+	return events
+}
+
+// String describes the cascade level by level, naming objects that are the
+// only ones of their kind at a level and counting the others.
+func (c *cascade) String() string {
+	var levels [][]*gcNode
+	for _, n := range c.nodes {
+		for len(levels) <= n.depth {
+			levels = append(levels, nil)
+		}
+		levels[n.depth] = append(levels[n.depth], n)
+	}
+	parts := make([]string, len(levels))
+	for i, level := range levels {
+		var kinds []string
+		byKind := map[string][]*gcNode{}
+		for _, n := range level {
+			kind := n.Object.GetKind()
+			if byKind[kind] == nil {
+				kinds = append(kinds, kind)
+			}
+			byKind[kind] = append(byKind[kind], n)
+		}
+		var names []string
+		for _, kind := range kinds {
+			if nodes := byKind[kind]; len(nodes) == 1 {
+				names = append(names, kind+"/"+nodes[0].Object.GetName())
+			} else {
+				names = append(names, fmt.Sprintf("%d %s", len(nodes), plural(kind)))
+			}
+		}
+		parts[i] = strings.Join(names, ", ")
+	}
+	parts[0] += " deleted"
+	return strings.Join(parts, " -> ")
+}
+
+func plural(kind string) string {
+	switch {
+	case strings.HasSuffix(kind, "s"), strings.HasSuffix(kind, "x"):
+		return kind + "es"
+	case strings.HasSuffix(kind, "y") && !strings.HasSuffix(kind, "ey"):
+		return kind[:len(kind)-1] + "ies"
+	}
+	return kind + "s"
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff/formatter"
+)
+
+var (
+	diffAddSymbol = pflag.String("diff-add-symbol", "+", "Symbol in the gutter of the lines diffs add")
+	diffDelSymbol = pflag.String("diff-del-symbol", "-", "Symbol in the gutter of the lines diffs remove")
+	diffIndent    = pflag.Int("diff-indent", 2, "Number of spaces diffs indent every level of nested fields by")
+)
+
+// plainStyle is the style given to the markers without one when diffs are
+// restyled, so that the formatter starts every line it writes with a color
+// sequence, which sets them apart from the further lines of multi-line
+// strings.
+const plainStyle = "0"
+
+// restyled reports whether the gutter or indentation of diffs is changed.
+func restyled() bool {
+	return *diffAddSymbol != "+" || *diffDelSymbol != "-" || *diffIndent != 2
+}
+
+func validateDiffStyle() error {
+	if *diffIndent < 0 {
+		return fmt.Errorf("--diff-indent must not be negative, got %d", *diffIndent)
+	}
+	if restyled() {
+		for _, marker := range []string{formatter.AsciiSame, formatter.AsciiAdded, formatter.AsciiDeleted} {
+			if _, ok := formatter.AsciiStyles[marker]; !ok {
+				formatter.AsciiStyles[marker] = plainStyle
+			}
+		}
+	}
+	return nil
+}
+
+// gutter returns what replaces the marker of a diff line, the symbols padded
+// to the same width so that lines stay aligned.
+func gutter(marker byte) string {
+	width := max(utf8.RuneCountInString(*diffAddSymbol), utf8.RuneCountInString(*diffDelSymbol))
+	var symbol string
+	switch marker {
+	case '+':
+		symbol = *diffAddSymbol
+	case '-':
+		symbol = *diffDelSymbol
+	}
+	return symbol + strings.Repeat(" ", width-utf8.RuneCountInString(symbol))
+}
+
+// restyleDiff applies --diff-add-symbol, --diff-del-symbol and --diff-indent
+// to text from the ascii formatter, which colors every line when restyled.
+// Lines start with the color sequence, a marker and two spaces per level,
+// and end with a reset, after the further lines of multi-line strings if
+// any. The colors are removed again unless --color is set.
+func restyleDiff(text string) string {
+	if !restyled() {
+		return text
+	}
+	const reset = "\x1b[0m"
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		end := strings.IndexByte(l, 'm')
+		if !strings.HasPrefix(l, "\x1b[") || end < 0 || end+1 == len(l) {
+			continue
+		}
+		color, l := l[:end+1], l[end+1:]
+		if !*colorize {
+			color = ""
+			if i > 0 {
+				lines[i-1] = strings.TrimSuffix(lines[i-1], reset)
+			}
+		}
+		rest := l[1:]
+		body := strings.TrimLeft(rest, " ")
+		depth := (len(rest) - len(body)) / 2
+		lines[i] = color + gutter(l[0]) + strings.Repeat(" ", depth**diffIndent) + body
+	}
+	if n := len(lines) - 2; n >= 0 && !*colorize {
+		lines[n] = strings.TrimSuffix(lines[n], reset)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var (
+	healthAddr = pflag.String("health-addr", "", "Serve the state of the watchers as JSON on /healthz and /watchers at this address, e.g. :8080")
+
+	watchHealth *healthRegistry
+)
+
+const (
+	watcherConnected    = "connected"
+	watcherReconnecting = "reconnecting"
+	watcherPolling      = "polling"
+	watcherStopped      = "stopped"
+)
+
+// A healthRegistry tracks the state of the watcher of every resource.
+type healthRegistry struct {
+	mu       sync.Mutex
+	watchers map[string]*watcherHealth
+}
+
+type watcherHealth struct {
+	r          *healthRegistry
+	Cluster    string     `json:"cluster,omitempty"`
+	Resource   string     `json:"resource"`
+	State      string     `json:"state"`
+	LastEvent  *time.Time `json:"lastEvent,omitempty"`
+	Reconnects int        `json:"reconnects"`
+}
+
+// healthOf returns the health of the watcher of gvr, or nil without
+// --health-addr. All methods of watcherHealth are no-ops on nil.
+func healthOf(cl *Cluster, gvr schema.GroupVersionResource) *watcherHealth {
+	r := watchHealth
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := cursorKey(cl, gvr)
+	h, ok := r.watchers[key]
+	if !ok {
+		h = &watcherHealth{r: r, Cluster: cl.label, Resource: gvr.GroupVersion().String() + "/" + gvr.Resource, State: watcherStopped}
+		r.watchers[key] = h
+	}
+	return h
+}
+
+func (h *watcherHealth) set(state string) {
+	if h == nil {
+		return
+	}
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	if state == watcherReconnecting && h.State != watcherReconnecting {
+		h.Reconnects++
+	}
+	h.State = state
+}
+
+// observed records an event received from the watch, which also shows that
+// a reconnecting watch is back.
+func (h *watcherHealth) observed() {
+	if h == nil {
+		return
+	}
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	now := Now()
+	h.LastEvent = &now
+	if h.State == watcherReconnecting {
+		h.State = watcherConnected
+	}
+}
+
+func (r *healthRegistry) snapshot() []watcherHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	watchers := make([]watcherHealth, 0, len(r.watchers))
+	for _, h := range r.watchers {
+		watchers = append(watchers, *h)
+	}
+	sort.Slice(watchers, func(i, j int) bool {
+		if watchers[i].Cluster != watchers[j].Cluster {
+			return watchers[i].Cluster < watchers[j].Cluster
+		}
+		return watchers[i].Resource < watchers[j].Resource
+	})
+	return watchers
+}
+
+// healthz reports the number of watchers in each state. It fails while any
+// of them is reconnecting; stopped ones are not counted against it, since
+// resources that cannot be watched are given up on by design.
+func (r *healthRegistry) healthz(w http.ResponseWriter, _ *http.Request) {
+	status := struct {
+		Status   string         `json:"status"`
+		Watchers map[string]int `json:"watchers"`
+	}{"ok", map[string]int{}}
+	for _, h := range r.snapshot() {
+		status.Watchers[h.State]++
+	}
+	if status.Watchers[watcherReconnecting] > 0 {
+		status.Status = "degraded"
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (r *healthRegistry) list(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, r.snapshot())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.V(2).Info("error writing health response: ", err)
+	}
+}
+
+// serve listens on addr and serves the registry until stopCh is
+// closed.
+func (r *healthRegistry) serve(addr string, stopCh <-chan struct{}) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.healthz)
+	mux.HandleFunc("/watchers", r.list)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			klog.Error("error serving health: ", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const autoscalingGroup = "autoscaling"
+
+// metricSources maps the types of autoscaling/v2 metrics to the fields
+// holding them.
+var metricSources = map[string]string{
+	"Resource":          "resource",
+	"ContainerResource": "containerResource",
+	"Pods":              "pods",
+	"Object":            "object",
+	"External":          "external",
+}
+
+// summarizeHPA reports the replica counts of a HorizontalPodAutoscaler along
+// with the metrics behind them, e.g. "replicas 3 -> 5 (cpu 85%)", and the
+// transitions of its conditions.
+func summarizeHPA(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: replicas %s, min %s, max %s", hpaInt(new, "status", "desiredReplicas"),
+			hpaInt(new, "spec", "minReplicas"), hpaInt(new, "spec", "maxReplicas"))
+	case len(new.Object) == 0:
+		return "deleted: replicas " + hpaInt(old, "status", "currentReplicas")
+	}
+	var lines []string
+	if before, after := hpaInt(old, "status", "desiredReplicas"), hpaInt(new, "status", "desiredReplicas"); before != after {
+		l := fmt.Sprintf("replicas %s -> %s", before, after)
+		if m := hpaMetrics(new); m != "" {
+			l += " (" + m + ")"
+		}
+		lines = append(lines, l)
+	}
+	if before, after := hpaInt(old, "status", "currentReplicas"), hpaInt(new, "status", "currentReplicas"); before != after {
+		lines = append(lines, fmt.Sprintf("current replicas %s -> %s", before, after))
+	}
+	lines = append(lines, conditionTransitions(old, new)...)
+	return strings.Join(lines, "\n")
+}
+
+func hpaInt(o *unstructured.Unstructured, fields ...string) string {
+	n, ok, _ := unstructured.NestedInt64(o.Object, fields...)
+	if !ok {
+		return "<none>"
+	}
+	return fmt.Sprint(n)
+}
+
+// hpaMetrics describes the current metrics of an autoscaling/v2 object, or
+// the CPU utilization of an autoscaling/v1 one.
+func hpaMetrics(o *unstructured.Unstructured) string {
+	if cpu, ok, _ := unstructured.NestedInt64(o.Object, "status", "currentCPUUtilizationPercentage"); ok {
+		return fmt.Sprintf("cpu %d%%", cpu)
+	}
+	metrics, _, _ := unstructured.NestedSlice(o.Object, "status", "currentMetrics")
+	var parts []string
+	for _, m := range metrics {
+		m, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _, _ := unstructured.NestedString(m, "type")
+		source, _, _ := unstructured.NestedMap(m, metricSources[typ])
+		name, _, _ := unstructured.NestedString(source, "name")
+		if name == "" {
+			name, _, _ = unstructured.NestedString(source, "metric", "name")
+		}
+		current, _, _ := unstructured.NestedMap(source, "current")
+		if u, ok, _ := unstructured.NestedInt64(current, "averageUtilization"); ok {
+			parts = append(parts, fmt.Sprintf("%s %d%%", name, u))
+		} else if v, ok := current["averageValue"]; ok {
+			parts = append(parts, fmt.Sprintf("%s %v", name, v))
+		} else if v, ok := current["value"]; ok {
+			parts = append(parts, fmt.Sprintf("%s %v", name, v))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
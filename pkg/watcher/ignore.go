@@ -0,0 +1,62 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	ignoreFields = pflag.StringSlice("ignore-field", nil, "Coma separated list of dotted field paths removed before diffing, e.g. metadata.labels.pod-template-hash (* matches any key)")
+
+	ignoreFieldSegments [][]string
+)
+
+func compileIgnoredFields() {
+	for _, p := range *ignoreFields {
+		ignoreFieldSegments = append(ignoreFieldSegments, strings.Split(p, "."))
+	}
+}
+
+// removeIgnoredFields deletes the --ignore-field paths from obj in place.
+func removeIgnoredFields(obj map[string]interface{}) {
+	for _, segments := range ignoreFieldSegments {
+		removePath(obj, segments)
+	}
+}
+
+func removePath(v interface{}, segments []string) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			removePath(item, segments)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if segments[0] != "*" && segments[0] != key {
+				continue
+			}
+			if len(segments) == 1 {
+				delete(v, key)
+			} else {
+				removePath(value, segments[1:])
+			}
+		}
+	}
+}
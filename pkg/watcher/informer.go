@@ -0,0 +1,130 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+var backend = pflag.String("backend", "watch", "How resources are followed: watch (list and watch each resource directly) or informer (client-go dynamic informers)")
+
+func spawnInformers(cl *Cluster, q *listQueue, out chan<- *Event, listed *sync.WaitGroup, stopCh <-chan struct{}) {
+	for {
+		gvr, ok := q.next()
+		if !ok {
+			return
+		}
+		if !memory.waitForMemory(gvr.String(), stopCh) {
+			return
+		}
+		if accessAllowed(cl, gvr) {
+			runInformer(cl, gvr, out, stopCh)
+		}
+		q.done(gvr)
+		listed.Done()
+	}
+}
+
+// runInformer starts an informer feeding changes of gvr to out and returns
+// once its initial list has been cached. Resources that cannot be listed are
+// given up on, like the watch backend does.
+func runInformer(cl *Cluster, gvr schema.GroupVersionResource, out chan<- *Event, stopCh <-chan struct{}) {
+	informer := dynamicinformer.NewFilteredDynamicInformer(cl.dc, gvr, metav1.NamespaceAll, 0, cache.Indexers{}, func(opts *metav1.ListOptions) {
+		opts.LabelSelector = *labelSelector
+		opts.FieldSelector = *fieldSelector
+	}).Informer()
+
+	objects := newObjectCache(cl, gvr)
+	sample := newSampler(cl, gvr)
+	health := healthOf(cl, gvr)
+	handle := func(t watch.EventType, obj interface{}, initial bool) {
+		if !initial {
+			health.observed()
+		}
+		if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = d.Obj
+		}
+		o, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		if initial && *dumpInitial && !shownInitially(o) && namespaceFilter(o.GetNamespace()) && !excludedName(o.GetName()) && matchesWhere(o) {
+			p := prepareObject(o)
+			if e := snapshotEvent(Now(), getKey(p), p); e != nil {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+		// The initial list only seeds the cache, as cacheResource does.
+		if e := processEvent(watch.Event{Type: t, Object: o}, objects); e != nil && (!initial || shownInitially(o)) && sample.keep(e) {
+			e.Cluster = cl.label
+			e.Resource = gvr
+			out <- e
+		}
+	}
+	reg, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    func(obj interface{}, initial bool) { handle(watch.Added, obj, initial) },
+		UpdateFunc: func(_, obj interface{}) { handle(watch.Modified, obj, false) },
+		DeleteFunc: func(obj interface{}) { handle(watch.Deleted, obj, false) },
+	})
+	if err != nil {
+		klog.Errorf("error watching resources '%v': %v", gvr, err)
+		return
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		if !reg.HasSynced() && (errors.IsForbidden(err) || errors.IsNotFound(err) || errors.IsMethodNotSupported(err)) {
+			if failOn(err) {
+				klog.Errorf("error listing '%v': %v", gvr, err)
+			} else {
+				klog.V(2).Infof("error listing '%v': %v", gvr, err)
+			}
+			stopOnce.Do(func() { close(stop) })
+			return
+		}
+		health.set(watcherReconnecting)
+		cache.DefaultWatchErrorHandler(r, err)
+	})
+	go func() {
+		select {
+		case <-stopCh:
+			stopOnce.Do(func() { close(stop) })
+		case <-stop:
+		}
+		health.set(watcherStopped)
+	}()
+
+	goWorker(func() { informer.Run(stop) })
+	if cache.WaitForCacheSync(stop, reg.HasSynced) {
+		health.set(watcherConnected)
+		klog.V(2).Infof("listed %d objects of '%v'", len(informer.GetStore().ListKeys()), gvr)
+	}
+}
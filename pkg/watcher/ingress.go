@@ -0,0 +1,147 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	networkingGroup = "networking.k8s.io"
+	gatewayGroup    = "gateway.networking.k8s.io"
+)
+
+func summarizeIngress(old, new *unstructured.Unstructured) string {
+	return summarizeSets(old, new, "route", ingressRoutes)
+}
+
+func summarizeHTTPRoute(old, new *unstructured.Unstructured) string {
+	return summarizeSets(old, new, "route", httpRoutes)
+}
+
+// ingressRoutes describes every path of an Ingress, e.g.
+// "example.com/api -> api:8080".
+func ingressRoutes(ing *unstructured.Unstructured) sets.Set[string] {
+	set := sets.New[string]()
+	if b, ok, _ := unstructured.NestedMap(ing.Object, "spec", "defaultBackend"); ok {
+		set.Insert("default -> " + ingressBackend(b))
+	}
+	rules, _, _ := unstructured.NestedSlice(ing.Object, "spec", "rules")
+	for _, r := range rules {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host, _, _ := unstructured.NestedString(m, "host")
+		if host == "" {
+			host = "*"
+		}
+		paths, _, _ := unstructured.NestedSlice(m, "http", "paths")
+		for _, p := range paths {
+			pm, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path, _, _ := unstructured.NestedString(pm, "path")
+			if path == "" {
+				path = "/"
+			}
+			b, _, _ := unstructured.NestedMap(pm, "backend")
+			set.Insert(host + path + " -> " + ingressBackend(b))
+		}
+	}
+	return set
+}
+
+// ingressBackend describes a backend as service:port or kind/name.
+func ingressBackend(b map[string]interface{}) string {
+	if name, ok, _ := unstructured.NestedString(b, "service", "name"); ok {
+		if port, ok, _ := unstructured.NestedInt64(b, "service", "port", "number"); ok {
+			return fmt.Sprintf("%s:%d", name, port)
+		}
+		port, _, _ := unstructured.NestedString(b, "service", "port", "name")
+		return name + ":" + port
+	}
+	kind, _, _ := unstructured.NestedString(b, "resource", "kind")
+	name, _, _ := unstructured.NestedString(b, "resource", "name")
+	return kind + "/" + name
+}
+
+// httpRoutes describes every path match of a Gateway API HTTPRoute along with
+// the backends its rule sends traffic to, e.g. "example.com/api -> api:8080".
+func httpRoutes(route *unstructured.Unstructured) sets.Set[string] {
+	hosts, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hosts) == 0 {
+		hosts = []string{"*"}
+	}
+	set := sets.New[string]()
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, r := range rules {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var backends []string
+		refs, _, _ := unstructured.NestedSlice(m, "backendRefs")
+		for _, ref := range refs {
+			rm, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(rm, "name")
+			if port, ok, _ := unstructured.NestedInt64(rm, "port"); ok {
+				name = fmt.Sprintf("%s:%d", name, port)
+			}
+			if kind, _, _ := unstructured.NestedString(rm, "kind"); kind != "" && kind != "Service" {
+				name = kind + "/" + name
+			}
+			backends = append(backends, name)
+		}
+		sort.Strings(backends)
+		target := strings.Join(backends, ",")
+		if target == "" {
+			target = "<none>"
+		}
+
+		paths := []string{"/"}
+		if matches, _, _ := unstructured.NestedSlice(m, "matches"); len(matches) > 0 {
+			paths = paths[:0]
+			for _, match := range matches {
+				mm, ok := match.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				path, _, _ := unstructured.NestedString(mm, "path", "value")
+				if path == "" {
+					path = "/"
+				}
+				paths = append(paths, path)
+			}
+		}
+		for _, host := range hosts {
+			for _, path := range paths {
+				set.Insert(host + path + " -> " + target)
+			}
+		}
+	}
+	return set
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const batchGroup = "batch"
+
+// summarizeJob reports the progress of a Job on one line, e.g.
+// "job X: active 2 -> 0, succeeded 0 -> 2 (Complete)".
+func summarizeJob(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("job %s: created, completions %s, parallelism %s", new.GetName(),
+			jobCount(new, "spec", "completions"), jobCount(new, "spec", "parallelism"))
+	case len(new.Object) == 0:
+		return fmt.Sprintf("job %s: deleted", old.GetName())
+	}
+	var changes []string
+	for _, field := range []string{"active", "succeeded", "failed"} {
+		if before, after := jobCount(old, "status", field), jobCount(new, "status", field); before != after {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", field, before, after))
+		}
+	}
+	var reached []string
+	before := conditionsByType(old)
+	for _, c := range conditions(new) {
+		typ, _, _ := unstructured.NestedString(c, "type")
+		status, _, _ := unstructured.NestedString(c, "status")
+		if status != "True" {
+			continue
+		}
+		if p, ok := before[typ]; ok {
+			if prev, _, _ := unstructured.NestedString(p, "status"); prev == "True" {
+				continue
+			}
+		}
+		if reason, _, _ := unstructured.NestedString(c, "reason"); reason != "" {
+			typ += ": " + reason
+		}
+		reached = append(reached, typ)
+	}
+	if len(changes) == 0 && len(reached) == 0 {
+		return ""
+	}
+	line := "job " + new.GetName() + ":"
+	if len(changes) > 0 {
+		line += " " + strings.Join(changes, ", ")
+	}
+	if len(reached) > 0 {
+		line += " (" + strings.Join(reached, ", ") + ")"
+	}
+	return line
+}
+
+// jobCount returns a count of a Job, which the API omits when it is zero.
+func jobCount(o *unstructured.Unstructured, fields ...string) string {
+	n, _, _ := unstructured.NestedInt64(o.Object, fields...)
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// eventSchemaVersion is reported as "v" in every structured event. Bump it
+// whenever fields of jsonEvent are changed or removed.
+const eventSchemaVersion = 1
+
+var jsonPretty = pflag.Bool("json-pretty", false, "Indent every event of json and ndjson output, which no longer puts one event per line")
+
+// jsonEvent is how an event is encoded by the JSONFormatter. Markers only
+// have the version, a time and a marker.
+type jsonEvent struct {
+	V       int                    `json:"v"`
+	Seq     uint64                 `json:"seq,omitempty"`
+	Time    time.Time              `json:"time"`
+	Cluster string                 `json:"cluster,omitempty"`
+	GVR     *jsonResource          `json:"gvr,omitempty"`
+	Name    string                 `json:"name,omitempty"`
+	RV      string                 `json:"resourceVersion,omitempty"`
+	Node    string                 `json:"node,omitempty"`
+	Type    string                 `json:"type,omitempty"`
+	Diff    string                 `json:"diff,omitempty"`
+	Paths   []string               `json:"paths,omitempty"`
+	Summary *diffSummary           `json:"summary,omitempty"`
+	Delta   map[string]interface{} `json:"delta,omitempty"`
+	Object  map[string]interface{} `json:"object,omitempty"`
+	Skipped int                    `json:"skipped,omitempty"`
+	Marker  string                 `json:"marker,omitempty"`
+}
+
+// jsonResource is the resource of an event as discovered, which tells apart
+// resources of the same kind in different groups.
+type jsonResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+func jsonResourceOf(event *Event) *jsonResource {
+	if event.Resource.Empty() {
+		return nil
+	}
+	return &jsonResource{Group: event.Resource.Group, Version: event.Resource.Version, Resource: event.Resource.Resource}
+}
+
+// JSONFormatter prints an event per line, either as a JSON array (json) or
+// as newline delimited JSON (ndjson).
+type JSONFormatter struct {
+	Array      bool
+	Indent     bool
+	needsComma bool
+}
+
+func (f *JSONFormatter) Preamble() string {
+	if f.Array {
+		return "["
+	}
+	return ""
+}
+
+func (f *JSONFormatter) Epilogue() string {
+	if f.Array {
+		return "\n]\n"
+	}
+	return ""
+}
+
+func (f *JSONFormatter) Format(event *Event) string {
+	return f.encode(jsonEventOf(event))
+}
+
+func jsonEventOf(event *Event) jsonEvent {
+	e := jsonEvent{
+		V:       eventSchemaVersion,
+		Seq:     event.Seq,
+		Time:    event.Timestamp,
+		Cluster: event.Cluster,
+		GVR:     jsonResourceOf(event),
+		Name:    event.Name,
+		RV:      event.ResourceVersion,
+		Node:    event.Node,
+		Type:    string(event.Type),
+		Diff:    stripColors(event.Data),
+		Skipped: event.Skipped,
+		Summary: event.Summary,
+	}
+	if event.Object != nil {
+		e.Object = event.Object.Object
+	}
+	for _, p := range event.Paths {
+		e.Paths = append(e.Paths, p.String())
+	}
+	return e
+}
+
+func (f *JSONFormatter) Marker(ts time.Time, text string) string {
+	return f.encode(jsonEvent{V: eventSchemaVersion, Time: ts, Marker: text})
+}
+
+func (f *JSONFormatter) encode(e jsonEvent) string {
+	var b []byte
+	var err error
+	if f.Indent {
+		b, err = json.MarshalIndent(e, "", "  ")
+	} else {
+		b, err = json.Marshal(e)
+	}
+	if err != nil {
+		klog.Error("error encoding event: ", err)
+		return ""
+	}
+	if !f.Array {
+		return string(b) + "\n"
+	}
+	comma := ""
+	if f.needsComma {
+		comma = ","
+	}
+	f.needsComma = true
+	return comma + "\n" + string(b)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	kedaGroup = "keda.sh"
+
+	kedaPausedAnnotation         = "autoscaling.keda.sh/paused"
+	kedaPausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
+)
+
+// summarizeScaler reports the state of a KEDA ScaledObject or ScaledJob,
+// e.g. "idle -> active (ScalerActive)", along with changes to its replica
+// bounds, triggers and the health of their metrics.
+func summarizeScaler(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: %s, replicas %s, triggers %s", scalerState(new), scalerBounds(new), orNone(strings.Join(sets.List(scalerTriggers(new)), ",")))
+	case len(new.Object) == 0:
+		return "deleted: " + scalerState(old)
+	}
+	var lines []string
+	if before, after := scalerState(old), scalerState(new); before != after {
+		line := before + " -> " + after
+		if c, ok := conditionsByType(new)["Active"]; ok && !strings.HasPrefix(after, "paused") {
+			if reason, _, _ := unstructured.NestedString(c, "reason"); reason != "" {
+				line += " (" + reason + ")"
+			}
+		}
+		lines = append(lines, line)
+	}
+	if before, after := scalerBounds(old), scalerBounds(new); before != after {
+		lines = append(lines, fmt.Sprintf("replicas %s -> %s", before, after))
+	}
+	before, after := scalerTriggers(old), scalerTriggers(new)
+	for _, t := range sets.List(after.Difference(before)) {
+		lines = append(lines, "+trigger "+t)
+	}
+	for _, t := range sets.List(before.Difference(after)) {
+		lines = append(lines, "-trigger "+t)
+	}
+	lines = append(lines, scalerHealthChanges(old, new)...)
+	for _, t := range conditionTransitions(old, new) {
+		if typ := strings.Fields(t)[0]; typ != "Active" && typ != "Paused" {
+			lines = append(lines, t)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scalerState returns whether a scaler is paused, active or idle, that is
+// scaled to its minimum for lack of trigger activity.
+func scalerState(o *unstructured.Unstructured) string {
+	annotations := o.GetAnnotations()
+	if n, ok := annotations[kedaPausedReplicasAnnotation]; ok {
+		return "paused at " + n + " replicas"
+	}
+	if annotations[kedaPausedAnnotation] == "true" {
+		return "paused"
+	}
+	c := conditionsByType(o)
+	if status, _, _ := unstructured.NestedString(c["Paused"], "status"); status == "True" {
+		return "paused"
+	}
+	if status, _, _ := unstructured.NestedString(c["Active"], "status"); status == "True" {
+		return "active"
+	}
+	return "idle"
+}
+
+func scalerBounds(o *unstructured.Unstructured) string {
+	return hpaInt(o, "spec", "minReplicaCount") + ".." + hpaInt(o, "spec", "maxReplicaCount")
+}
+
+// scalerTriggers describes the triggers of a scaler by type, with their
+// names if they have one.
+func scalerTriggers(o *unstructured.Unstructured) sets.Set[string] {
+	triggers, _, _ := unstructured.NestedSlice(o.Object, "spec", "triggers")
+	names := sets.New[string]()
+	for _, t := range triggers {
+		m, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _, _ := unstructured.NestedString(m, "type")
+		if name, _, _ := unstructured.NestedString(m, "name"); name != "" {
+			typ += "/" + name
+		}
+		names.Insert(typ)
+	}
+	return names
+}
+
+// scalerHealthChanges describes the metrics in status.health whose status
+// changed, e.g. "metric s0-prometheus Happy -> Failure (3 failures)".
+func scalerHealthChanges(old, new *unstructured.Unstructured) []string {
+	before, _, _ := unstructured.NestedMap(old.Object, "status", "health")
+	after, _, _ := unstructured.NestedMap(new.Object, "status", "health")
+	metrics := make([]string, 0, len(after))
+	for m := range after {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics)
+	var changes []string
+	for _, m := range metrics {
+		h, _ := after[m].(map[string]interface{})
+		status, _, _ := unstructured.NestedString(h, "status")
+		prev := "<none>"
+		if p, ok := before[m].(map[string]interface{}); ok {
+			prev, _, _ = unstructured.NestedString(p, "status")
+		}
+		if prev == status {
+			continue
+		}
+		change := fmt.Sprintf("metric %s %s -> %s", m, prev, status)
+		if n, _, _ := unstructured.NestedInt64(h, "numberOfFailures"); n > 0 {
+			change += fmt.Sprintf(" (%d failures)", n)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
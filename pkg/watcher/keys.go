@@ -0,0 +1,103 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+var (
+	interactive = pflag.Bool("interactive", false, "When attached to a terminal, toggle filters with key presses: s hides status-only changes, d shows only deletions, p pauses")
+
+	// keyPresses delivers keys typed in interactive mode. It is nil otherwise.
+	keyPresses chan byte
+)
+
+// startKeys puts the terminal in cbreak mode and starts reading key presses.
+// It does nothing unless both stdin and stdout are terminals. The returned
+// function restores the terminal.
+func startKeys() func() {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}
+	}
+	restore, err := setCbreak(int(os.Stdin.Fd()))
+	if err != nil {
+		klog.Warning("disabling interactive mode: ", err)
+		return func() {}
+	}
+	keyPresses = make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil {
+				return
+			} else if n == 1 {
+				keyPresses <- buf[0]
+			}
+		}
+	}()
+	return restore
+}
+
+// A view holds the filters toggled by key presses.
+type view struct {
+	hideStatus  bool
+	deletesOnly bool
+	paused      bool
+}
+
+// toggle flips the filter bound to key and reports whether it was one.
+func (v *view) toggle(key byte) bool {
+	var name string
+	var on bool
+	switch key {
+	case 's':
+		v.hideStatus = !v.hideStatus
+		name, on = "hiding status-only changes", v.hideStatus
+	case 'd':
+		v.deletesOnly = !v.deletesOnly
+		name, on = "showing deletions only", v.deletesOnly
+	case 'p':
+		v.paused = !v.paused
+		name, on = "paused", v.paused
+	default:
+		return false
+	}
+	state := "off"
+	if on {
+		state = "on"
+	}
+	fmt.Fprintf(os.Stderr, "--- %s: %s ---\n", name, state)
+	return true
+}
+
+func (v *view) shows(e *Event) bool {
+	if v.hideStatus && isStatusOnly(e.Paths) {
+		return false
+	}
+	if v.deletesOnly && e.Type != watch.Deleted {
+		return false
+	}
+	return true
+}
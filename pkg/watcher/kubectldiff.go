@@ -0,0 +1,216 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// diffContext is the number of unchanged lines around changes, as with
+// diff -u.
+const diffContext = 3
+
+// KubectlDiffFormatter prints every event the way kubectl diff prints a
+// change to a single object: diff -u -N of the YAML of the object before and
+// after it, named group.version.Kind.namespace.name in a LIVE and a MERGED
+// directory.
+type KubectlDiffFormatter struct{}
+
+func (f *KubectlDiffFormatter) Preamble() string {
+	return ""
+}
+
+func (f *KubectlDiffFormatter) Epilogue() string {
+	return ""
+}
+
+func (f *KubectlDiffFormatter) Format(event *Event) string {
+	var old, new *unstructured.Unstructured
+	switch event.Type {
+	case watch.Added:
+		new = event.Object
+	case watch.Modified:
+		old, new = event.Old, event.Object
+	case watch.Deleted:
+		old = event.Object
+	default:
+		return ""
+	}
+	a, err := diffYAML(old)
+	if err != nil {
+		klog.Error("error encoding object: ", err)
+		return ""
+	}
+	b, err := diffYAML(new)
+	if err != nil {
+		klog.Error("error encoding object: ", err)
+		return ""
+	}
+	hunks := unifiedHunks(splitLines(a), splitLines(b))
+	if hunks == "" {
+		return ""
+	}
+
+	name := diffFileName(event.Object)
+	if event.Cluster != "" {
+		name = path.Join(event.Cluster, name)
+	}
+	live := fmt.Sprintf("/tmp/LIVE-%d/%s", event.Seq, name)
+	merged := fmt.Sprintf("/tmp/MERGED-%d/%s", event.Seq, name)
+	ts := event.Timestamp.Format("2006-01-02 15:04:05.000000000 -0700")
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "diff -u -N %s %s\n", live, merged)
+	fmt.Fprintf(&buf, "--- %s\t%s\n", live, ts)
+	fmt.Fprintf(&buf, "+++ %s\t%s\n", merged, ts)
+	buf.WriteString(hunks)
+	return buf.String()
+}
+
+// Marker prints nothing, the output is a patch.
+func (f *KubectlDiffFormatter) Marker(ts time.Time, text string) string {
+	return ""
+}
+
+// diffFileName returns the name kubectl diff gives the file of o.
+func diffFileName(o *unstructured.Unstructured) string {
+	gvk := o.GroupVersionKind()
+	name := fmt.Sprintf("%s.%s.%s.%s", gvk.Version, gvk.Kind, o.GetNamespace(), o.GetName())
+	if gvk.Group != "" {
+		name = gvk.Group + "." + name
+	}
+	return name
+}
+
+// diffYAML returns o as kubectl diff prints it, without managedFields. A
+// missing or empty object yields an empty file.
+func diffYAML(o *unstructured.Unstructured) (string, error) {
+	if o == nil || len(o.Object) == 0 {
+		return "", nil
+	}
+	if _, ok, _ := unstructured.NestedFieldNoCopy(o.Object, "metadata", "managedFields"); ok {
+		o = o.DeepCopy()
+		o.SetManagedFields(nil)
+	}
+	b, err := yaml.Marshal(o.Object)
+	return string(b), err
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type unifiedLine struct {
+	op   byte
+	text string
+}
+
+// lineDiff returns the edit script turning the lines a into b, with the
+// removed lines of every change ahead of the added ones.
+func lineDiff(a, b []string) []unifiedLine {
+	var lines, added []unifiedLine
+	for _, row := range alignLines(a, b) {
+		switch row.op {
+		case opEqual:
+			lines = append(lines, added...)
+			added = added[:0]
+			lines = append(lines, unifiedLine{' ', row.left})
+		case opDelete:
+			lines = append(lines, unifiedLine{'-', row.left})
+		case opInsert:
+			added = append(added, unifiedLine{'+', row.right})
+		case opChange:
+			lines = append(lines, unifiedLine{'-', row.left})
+			added = append(added, unifiedLine{'+', row.right})
+		}
+	}
+	return append(lines, added...)
+}
+
+// unifiedHunks returns the hunks of a unified diff of the lines a and b, or ""
+// if they are the same.
+func unifiedHunks(a, b []string) string {
+	// Only the lines within the context of the first and last change are
+	// aligned.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	skip, trail := max(prefix-diffContext, 0), max(suffix-diffContext, 0)
+	ops := lineDiff(a[skip:len(a)-trail], b[skip:len(b)-trail])
+	// oldLine and newLine hold the number of lines of a and b before each op.
+	oldLine, newLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = skip, skip
+	var changes []int
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.op != '+' {
+			oldLine[i+1]++
+		}
+		if op.op != '-' {
+			newLine[i+1]++
+		}
+		if op.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(changes); {
+		// Changes less than two contexts apart share a hunk.
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext+1 {
+			j++
+		}
+		start := max(changes[i]-diffContext, 0)
+		end := min(changes[j]+diffContext+1, len(ops))
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldLine[end]-oldLine[start]), hunkRange(newLine[start], newLine[end]-newLine[start]))
+		for _, op := range ops[start:end] {
+			buf.WriteByte(op.op)
+			buf.WriteString(op.text)
+			buf.WriteByte('\n')
+		}
+		i = j + 1
+	}
+	return buf.String()
+}
+
+// hunkRange formats the count lines after the first ones of a file as diff -u
+// does.
+func hunkRange(first, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", first)
+	case 1:
+		return fmt.Sprint(first + 1)
+	}
+	return fmt.Sprintf("%d,%d", first+1, count)
+}
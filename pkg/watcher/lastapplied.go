@@ -0,0 +1,42 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/pflag"
+)
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+var decodeLastApplied = pflag.Bool("decode-last-applied", false, "Instead of ignoring the "+lastAppliedAnnotation+" annotation, decode it and show what kubectl apply changed as a nested diff")
+
+// decodeLastAppliedConfig replaces the last applied configuration in the
+// annotations of m, if any, with the object it encodes.
+func decodeLastAppliedConfig(m map[string]interface{}) {
+	metadata, _ := m["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	s, ok := annotations[lastAppliedAnnotation].(string)
+	if !ok {
+		return
+	}
+	var config interface{}
+	if err := json.Unmarshal([]byte(s), &config); err == nil {
+		annotations[lastAppliedAnnotation] = config
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff/formatter"
+)
+
+var showLegend = pflag.Bool("legend", false, "Print a legend of the colors and symbols of the output before the first event, with the default and side-by-side formats")
+
+// A legendFormatter explains what its output looks like.
+type legendFormatter interface {
+	Legend() string
+}
+
+// legend writes the legend of every sink with a formatter that has one when
+// --legend is set.
+func (s sinks) legend() {
+	if !*showLegend {
+		return
+	}
+	for _, sink := range s {
+		if f, ok := sink.Formatter.(legendFormatter); ok {
+			sink.Writer.Write([]byte(f.Legend()))
+		}
+	}
+}
+
+// Legend shows the gutter and colors of diff lines as the ascii formatter
+// writes them, along with those of notes and headers.
+func (f *DefaultFormatter) Legend() string {
+	return f.legend([][2]string{
+		{gutter('+') + " added lines", formatter.AsciiStyles[formatter.AsciiAdded]},
+		{gutter('-') + " removed lines", formatter.AsciiStyles[formatter.AsciiDeleted]},
+		{gutter(' ') + " unchanged lines around them", formatter.AsciiStyles[formatter.AsciiSame]},
+	}, "added objects are shown whole as added lines, deleted ones as removed lines")
+}
+
+// Legend shows the gutters and colors of the columns of side-by-side diffs.
+func (f *SideBySideFormatter) Legend() string {
+	plain := f.plain(&Event{}) || !f.Color
+	cell := func(s, marker string) string {
+		if plain {
+			return s
+		}
+		return colorText(s, formatter.AsciiStyles[marker])
+	}
+	lines := [][2]string{
+		{"left column: before the change, right column: after it", ""},
+		{cell("old", formatter.AsciiDeleted) + gutters[opChange] + cell("new", formatter.AsciiAdded) + "  changed lines", ""},
+		{cell("old", formatter.AsciiDeleted) + gutters[opDelete] + "     removed lines", ""},
+		{"   " + gutters[opInsert] + cell("new", formatter.AsciiAdded) + "  added lines", ""},
+	}
+	return f.legend(lines, "added objects only fill the right column, deleted ones the left")
+}
+
+// legend renders lines, each in its style, followed by note and the lines
+// common to the default formats.
+func (f *DefaultFormatter) legend(lines [][2]string, note string) string {
+	lines = append(lines,
+		[2]string{"[time] namespace/name apiVersion/kind  event headers, " + note, headerStyle},
+		[2]string{"notes above diffs, e.g. about terminations or managers", noteStyle},
+		[2]string{"alerts about failures, e.g. of stuck objects", alertStyle},
+	)
+	if *colorBy != "none" && !f.NoColor {
+		lines = append(lines, [2]string{"headers are colored by their " + *colorBy, ""})
+	}
+	var buf strings.Builder
+	buf.WriteString("legend:\n")
+	for _, l := range lines {
+		text, style := l[0], l[1]
+		if !f.NoColor && style != "" {
+			text = colorText(text, style)
+		}
+		buf.WriteString("  " + text + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
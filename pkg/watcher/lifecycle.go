@@ -0,0 +1,156 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var (
+	watchNamespacesLifecycle = pflag.Bool("watch-namespaces-lifecycle", false, "Watch only Namespaces and report their creation, deletion, phase and condition transitions, and those stuck terminating for longer than --namespace-stuck-after")
+	namespaceStuckAfter      = pflag.Duration("namespace-stuck-after", 5*time.Minute, "How long a namespace can be terminating before --watch-namespaces-lifecycle reports it as stuck")
+)
+
+// summarizeNamespace reports the phase and condition transitions of a
+// Namespace, e.g. "Active -> Terminating" and "NamespaceContentRemaining
+// <none> -> True (SomeResourcesRemain): Some resources are remaining: pods.
+// has 2 resource instances".
+func summarizeNamespace(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		if ts := new.GetDeletionTimestamp(); ts != nil {
+			return "terminating for " + duration.HumanDuration(Now().Sub(ts.Time))
+		}
+		return "created: " + orNone(namespacePhase(new))
+	case len(new.Object) == 0:
+		if ts := old.GetDeletionTimestamp(); ts != nil {
+			return "deleted after terminating for " + duration.HumanDuration(Now().Sub(ts.Time))
+		}
+		return "deleted"
+	}
+	var lines []string
+	if before, after := namespacePhase(old), namespacePhase(new); before != after {
+		lines = append(lines, fmt.Sprintf("%s -> %s", orNone(before), orNone(after)))
+	}
+	after := conditionsByType(new)
+	for _, t := range conditionTransitions(old, new) {
+		typ, _, _ := strings.Cut(t, " ")
+		if msg, _, _ := unstructured.NestedString(after[typ], "message"); msg != "" {
+			t += ": " + msg
+		}
+		lines = append(lines, t)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shownInitially reports whether the listed object o is shown as added
+// instead of only being cached.
+func shownInitially(o *unstructured.Unstructured) bool {
+	return modifiedSince(o) || *watchNamespacesLifecycle && o.GetKind() == "Namespace" && o.GetDeletionTimestamp() != nil
+}
+
+func namespacePhase(o *unstructured.Unstructured) string {
+	phase, _, _ := unstructured.NestedString(o.Object, "status", "phase")
+	return phase
+}
+
+// stuckNamespaces follows the namespaces being terminated to report those
+// still around --namespace-stuck-after their deletion, once each, even if
+// nothing about them changes. It is used by a single goroutine.
+type stuckNamespaces struct {
+	after time.Duration
+	// terminating holds the last event of every terminating namespace not
+	// reported yet, and reported the names of those that were.
+	terminating map[string]*Event
+	reported    map[string]bool
+}
+
+// newStuckNamespaces returns nil without --watch-namespaces-lifecycle.
+func newStuckNamespaces() *stuckNamespaces {
+	if !*watchNamespacesLifecycle {
+		return nil
+	}
+	return &stuckNamespaces{after: *namespaceStuckAfter, terminating: map[string]*Event{}, reported: map[string]bool{}}
+}
+
+func (s *stuckNamespaces) observe(e *Event) {
+	if s == nil || e.Object == nil || e.Object.GetKind() != "Namespace" {
+		return
+	}
+	name := e.FullName()
+	switch {
+	case e.Type == watch.Deleted || e.Object.GetDeletionTimestamp() == nil:
+		delete(s.terminating, name)
+		delete(s.reported, name)
+	case !s.reported[name]:
+		s.terminating[name] = e
+	}
+}
+
+// timer fires when the next terminating namespace becomes stuck.
+func (s *stuckNamespaces) timer() <-chan time.Time {
+	if s == nil || len(s.terminating) == 0 {
+		return nil
+	}
+	var next time.Time
+	for _, e := range s.terminating {
+		if t := e.Object.GetDeletionTimestamp().Add(s.after); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return time.After(time.Until(next))
+}
+
+// due returns a warning about every namespace that became stuck, with the
+// content or finalizers it is waiting for.
+func (s *stuckNamespaces) due() []*Event {
+	now := Now()
+	var events []*Event
+	for name, e := range s.terminating {
+		since := e.Object.GetDeletionTimestamp().Time
+		if now.Sub(since) < s.after {
+			continue
+		}
+		msg := "stuck terminating for " + duration.HumanDuration(now.Sub(since))
+		conds := conditionsByType(e.Object)
+		for _, typ := range []string{"NamespaceContentRemaining", "NamespaceFinalizersRemaining", "NamespaceDeletionContentFailure"} {
+			if status, _, _ := unstructured.NestedString(conds[typ], "status"); status != "True" {
+				continue
+			}
+			if m, _, _ := unstructured.NestedString(conds[typ], "message"); m != "" {
+				msg += "\n" + m
+			}
+		}
+		w := *e
+		w.Timestamp, w.Type, w.Data = now, watch.Modified, colorText(msg, alertStyle)
+		w.Old, w.Paths, w.Summary, w.Initial = e.Object, nil, nil, false
+		events = append(events, &w)
+		delete(s.terminating, name)
+		s.reported[name] = true
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].FullName() < events[j].FullName() })
+	return events
+}
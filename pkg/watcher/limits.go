@@ -0,0 +1,52 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+var maxObjectSize = pflag.Int64("max-object-size", 0, "Skip objects whose approximate in-memory size exceeds this many bytes, such as huge CRDs or Secrets (0 disables)")
+
+// tooLarge reports whether o exceeds --max-object-size, warning about it if
+// so.
+func tooLarge(o *unstructured.Unstructured) bool {
+	if *maxObjectSize <= 0 {
+		return false
+	}
+	if size := approxSize(o.Object); size > *maxObjectSize {
+		klog.Warningf("skipping %s, its size of about %d bytes exceeds --max-object-size", getKey(o), size)
+		return true
+	}
+	return false
+}
+
+// watchDecodeCause is the cause of the error a watch sends, and then stops,
+// when it cannot decode an event, such as one for an object too large to
+// decode.
+const watchDecodeCause metav1.CauseType = "ClientWatchDecoding"
+
+// undecodableEvent reports whether err means the watch failed to decode an
+// event.
+func undecodableEvent(err error) bool {
+	return errors.HasStatusCause(err, watchDecodeCause)
+}
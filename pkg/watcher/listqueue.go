@@ -0,0 +1,128 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var heavyResources = pflag.StringSlice("heavy-resources", []string{"pods", "events", "events.events.k8s.io", "endpoints", "endpointslices.discovery.k8s.io", "replicasets.apps"}, "Coma separated list of resources, like pods or replicasets.apps, that are large enough to take at most half of --list-concurrency during the initial sync, so that the other resources do not wait for them")
+
+// A listQueue hands out the resources to list to the list workers. Other
+// resources go first, and --heavy-resources are listed by at most half of
+// the workers at a time.
+type listQueue struct {
+	mu           sync.Mutex
+	ready        *sync.Cond
+	light, heavy []schema.GroupVersionResource
+	listingHeavy int
+	maxHeavy     int
+	closed       bool
+}
+
+// newListQueue returns the queue of the resources sent to in, for the given
+// number of list workers. It ends once in is closed and drained, or once
+// stopCh is closed.
+func newListQueue(in <-chan schema.GroupVersionResource, workers int, stopCh <-chan struct{}) *listQueue {
+	q := &listQueue{maxHeavy: max(workers/2, 1)}
+	q.ready = sync.NewCond(&q.mu)
+	go func() {
+		defer q.close()
+		for {
+			select {
+			case <-stopCh:
+				q.mu.Lock()
+				q.light, q.heavy = nil, nil
+				q.mu.Unlock()
+				return
+			case gvr, ok := <-in:
+				if !ok {
+					return
+				}
+				q.push(gvr)
+			}
+		}
+	}()
+	return q
+}
+
+func isHeavy(gvr schema.GroupVersionResource) bool {
+	for _, r := range *heavyResources {
+		if schema.ParseGroupResource(r) == gvr.GroupResource() {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *listQueue) push(gvr schema.GroupVersionResource) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if isHeavy(gvr) {
+		q.heavy = append(q.heavy, gvr)
+	} else {
+		q.light = append(q.light, gvr)
+	}
+	q.ready.Signal()
+}
+
+func (q *listQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.ready.Broadcast()
+}
+
+// next waits for the next resource to list, which a heavy one is only while
+// fewer than half of the workers list heavy resources. It returns false once
+// the queue has ended.
+func (q *listQueue) next() (schema.GroupVersionResource, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if len(q.light) != 0 {
+			gvr := q.light[0]
+			q.light = q.light[1:]
+			return gvr, true
+		}
+		if len(q.heavy) != 0 && q.listingHeavy < q.maxHeavy {
+			gvr := q.heavy[0]
+			q.heavy = q.heavy[1:]
+			q.listingHeavy++
+			return gvr, true
+		}
+		if q.closed && len(q.light) == 0 && len(q.heavy) == 0 {
+			return schema.GroupVersionResource{}, false
+		}
+		q.ready.Wait()
+	}
+}
+
+// done tells q that gvr, which next returned, is listed.
+func (q *listQueue) done(gvr schema.GroupVersionResource) {
+	if !isHeavy(gvr) {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.listingHeavy--
+	q.ready.Broadcast()
+}
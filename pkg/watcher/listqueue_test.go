@@ -0,0 +1,69 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestListQueue(t *testing.T) {
+	in := make(chan schema.GroupVersionResource, 10)
+	for _, r := range []string{"pods", "events", "endpoints", "configmaps", "secrets"} {
+		in <- schema.GroupVersionResource{Version: "v1", Resource: r}
+	}
+	close(in)
+	// Two of the four workers may list heavy resources.
+	q := newListQueue(in, 4, make(chan struct{}))
+
+	var heavy []schema.GroupVersionResource
+	light := 0
+	for i := 0; i < 4; i++ {
+		gvr, ok := q.next()
+		if !ok {
+			t.Fatalf("queue ended after %d resources", i)
+		}
+		if isHeavy(gvr) {
+			heavy = append(heavy, gvr)
+		} else {
+			light++
+		}
+	}
+	if len(heavy) != 2 || light != 2 {
+		t.Fatalf("got %d heavy and %d light resources, want 2 of each", len(heavy), light)
+	}
+
+	last := make(chan schema.GroupVersionResource)
+	go func() {
+		gvr, _ := q.next()
+		last <- gvr
+	}()
+	select {
+	case gvr := <-last:
+		t.Fatalf("got %v while two heavy resources are listed", gvr)
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.done(heavy[0])
+	if gvr := <-last; !isHeavy(gvr) {
+		t.Errorf("got %v, want the last heavy resource", gvr)
+	}
+	if gvr, ok := q.next(); ok {
+		t.Errorf("got %v after the last resource", gvr)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// The API server only serves the current state of an object, so the cached
+// state an update is diffed against cannot be re-fetched once dropped.
+// Instead, --low-memory keeps the objects that did not change between two
+// bookmarks encoded as JSON, which takes a fraction of the memory of decoded
+// objects, and decodes them again when they next change. That costs a decode
+// per first update after a quiet period, and an encode per idle object at
+// every bookmark, roughly once a minute.
+var lowMemory = pflag.Bool("low-memory", false, "Request watch bookmarks and keep objects that did not change between two of them encoded, trading CPU on their next change for a smaller cache")
+
+// compact encodes the objects that were not set since the last call.
+func (c *objectCache) compact() {
+	if c.idle == nil {
+		return
+	}
+	for key, o := range c.objects {
+		if c.recent[key] {
+			continue
+		}
+		data, err := o.MarshalJSON()
+		if err != nil {
+			klog.V(2).Infof("error encoding %s: %v", key, err)
+			continue
+		}
+		c.drop(key)
+		c.idle[key] = data
+		if c.stat != nil {
+			c.stat.objects.Add(1)
+			c.stat.bytes.Add(int64(len(data)))
+		}
+	}
+	c.recent = map[string]bool{}
+}
+
+// expand decodes the idle object of key.
+func (c *objectCache) expand(key string) (*unstructured.Unstructured, bool) {
+	data, ok := c.idle[key]
+	if !ok {
+		return nil, false
+	}
+	o := &unstructured.Unstructured{}
+	if err := o.UnmarshalJSON(data); err != nil {
+		klog.V(2).Infof("error decoding %s: %v", key, err)
+		return nil, false
+	}
+	return o, true
+}
@@ -0,0 +1,371 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	humanChangesOnly    = pflag.Bool("human-changes-only", false, "Only show changes made by interactive clients (see --human-managers). Deletions cannot be attributed and are dropped")
+	humanManagers       = pflag.StringSlice("human-managers", []string{"kubectl", "kubectl-*"}, "Coma separated list of field manager patterns considered interactive clients")
+	excludeManagedBy    = pflag.StringSlice("exclude-managed-by", nil, "Drop updates whose changed fields were all written by field managers matching one of these patterns, e.g. kube-controller-manager")
+	serverSideApplyOnly = pflag.Bool("server-side-apply-only", false, "Only show updates whose changed fields are all owned by server-side apply (Apply operations in managedFields), hiding imperative Update changes")
+	showManagerDiff     = pflag.Bool("show-manager-diff", false, "Summarize field managers that appeared or went away and fields whose ownership moved between managers above the diff")
+	showOwnershipTable  = pflag.Bool("show-ownership-table", false, "Print a table of the field managers owning every top-level field of changed objects above the diff")
+)
+
+// matchManagers reports whether any of managers matches one of the glob
+// patterns.
+func matchManagers(managers sets.String, patterns []string) bool {
+	for m := range managers {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, m); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allManagersMatch reports whether managers is not empty and each of them
+// matches one of the glob patterns.
+func allManagersMatch(managers sets.String, patterns []string) bool {
+	for m := range managers {
+		if !matchManagers(sets.NewString(m), patterns) {
+			return false
+		}
+	}
+	return managers.Len() != 0
+}
+
+// changeManagers returns the field managers responsible for the changed paths
+// of new.
+func changeManagers(old, new *unstructured.Unstructured, paths []fieldPath) sets.String {
+	managers := sets.String{}
+	for _, e := range changeEntries(old, new, paths) {
+		managers.Insert(e.Manager)
+	}
+	return managers
+}
+
+// onlyAppliedChange reports whether the changed paths of new were all made
+// by server-side apply. It is false when the change cannot be attributed.
+func onlyAppliedChange(old, new *unstructured.Unstructured, paths []fieldPath) bool {
+	entries := changeEntries(old, new, paths)
+	for _, e := range entries {
+		if e.Operation != metav1.ManagedFieldsOperationApply {
+			return false
+		}
+	}
+	return len(entries) != 0
+}
+
+// changeEntries returns the managedFields entries of new owning its changed
+// paths. Paths owned by no entry, such as removed fields, are attributed to
+// the entries updated by the change.
+func changeEntries(old, new *unstructured.Unstructured, paths []fieldPath) []metav1.ManagedFieldsEntry {
+	entries := new.GetManagedFields()
+	fields := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		if e.FieldsV1 != nil {
+			json.Unmarshal(e.FieldsV1.Raw, &fields[i])
+		}
+	}
+
+	responsible := make([]bool, len(entries))
+	unowned := false
+	for _, p := range paths {
+		if isBookkeepingPath(p) {
+			continue
+		}
+		owned := false
+		for i := range entries {
+			if len(fields[i]) != 0 && ownsPath(fields[i], new.Object, p) {
+				responsible[i] = true
+				owned = true
+			}
+		}
+		if !owned {
+			unowned = true
+		}
+	}
+	if unowned {
+		updated := updatedEntries(old, new)
+		for i := range entries {
+			responsible[i] = responsible[i] || updated[i]
+		}
+	}
+
+	var changed []metav1.ManagedFieldsEntry
+	for i, e := range entries {
+		if responsible[i] {
+			changed = append(changed, e)
+		}
+	}
+	return changed
+}
+
+// updatedEntries flags the managedFields entries of new that are missing
+// from or differ from those in old.
+func updatedEntries(old, new *unstructured.Unstructured) []bool {
+	type entryKey struct{ manager, operation, subresource string }
+	previous := map[entryKey]metav1.ManagedFieldsEntry{}
+	for _, e := range old.GetManagedFields() {
+		previous[entryKey{e.Manager, string(e.Operation), e.Subresource}] = e
+	}
+
+	entries := new.GetManagedFields()
+	updated := make([]bool, len(entries))
+	for i, e := range entries {
+		p, ok := previous[entryKey{e.Manager, string(e.Operation), e.Subresource}]
+		updated[i] = !ok || !p.Time.Equal(e.Time) || !fieldsEqual(p.FieldsV1, e.FieldsV1)
+	}
+	return updated
+}
+
+func fieldsEqual(a, b *metav1.FieldsV1) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.Raw, b.Raw)
+}
+
+// ownsPath reports whether the FieldsV1 set fields covers path within obj.
+func ownsPath(fields map[string]interface{}, obj interface{}, path fieldPath) bool {
+	if len(path) == 0 {
+		return true
+	}
+	switch seg := path[0].(type) {
+	case string:
+		child, ok := fields["f:"+seg].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		m, _ := obj.(map[string]interface{})
+		return len(child) == 0 || ownsPath(child, m[seg], path[1:])
+	case int:
+		a, _ := obj.([]interface{})
+		if seg >= len(a) {
+			return false
+		}
+		for k, child := range fields {
+			if !matchesListElement(k, seg, a[seg]) {
+				continue
+			}
+			c, _ := child.(map[string]interface{})
+			if len(c) == 0 || ownsPath(c, a[seg], path[1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesListElement reports whether the FieldsV1 list key k ("i:<index>",
+// "v:<value>" or "k:<key fields>") selects the element at index i.
+func matchesListElement(k string, i int, elem interface{}) bool {
+	switch {
+	case strings.HasPrefix(k, "i:"):
+		n, err := strconv.Atoi(k[2:])
+		return err == nil && n == i
+	case strings.HasPrefix(k, "v:"):
+		var v interface{}
+		if err := json.Unmarshal([]byte(k[2:]), &v); err != nil {
+			return false
+		}
+		return jsonEqual(v, elem)
+	case strings.HasPrefix(k, "k:"):
+		var keys map[string]interface{}
+		if err := json.Unmarshal([]byte(k[2:]), &keys); err != nil {
+			return false
+		}
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for name, v := range keys {
+			if !jsonEqual(v, m[name]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// jsonEqual compares values by their JSON encoding, which papers over the
+// int64/float64 difference between unstructured and encoding/json numbers.
+func jsonEqual(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+// managerChanges describes how field ownership changed from old to new: the
+// managers that appeared or went away and, grouped by previous and new
+// owners, the fields that moved between managers.
+func managerChanges(old, new *unstructured.Unstructured) []string {
+	before, after := fieldOwners(old), fieldOwners(new)
+	var changes []string
+
+	managersBefore, managersAfter := sets.String{}, sets.String{}
+	for _, m := range before {
+		managersBefore = managersBefore.Union(m)
+	}
+	for _, m := range after {
+		managersAfter = managersAfter.Union(m)
+	}
+	for _, m := range managersAfter.Difference(managersBefore).List() {
+		changes = append(changes, "manager "+m+" appeared")
+	}
+	for _, m := range managersBefore.Difference(managersAfter).List() {
+		changes = append(changes, "manager "+m+" went away")
+	}
+
+	moved := map[string][]string{}
+	for field, owners := range after {
+		lost, gained := before[field].Difference(owners), owners.Difference(before[field])
+		if lost.Len() == 0 || gained.Len() == 0 {
+			continue
+		}
+		move := strings.Join(lost.List(), ",") + " -> " + strings.Join(gained.List(), ",")
+		moved[move] = append(moved[move], field)
+	}
+	moves := make([]string, 0, len(moved))
+	for m := range moved {
+		moves = append(moves, m)
+	}
+	sort.Strings(moves)
+	for _, m := range moves {
+		sort.Strings(moved[m])
+		changes = append(changes, fmt.Sprintf("fields moved %s: %s", m, strings.Join(moved[m], " ")))
+	}
+	return changes
+}
+
+// fieldOwners maps every field in the managedFields of o to its managers.
+func fieldOwners(o *unstructured.Unstructured) map[string]sets.String {
+	owners := map[string]sets.String{}
+	for _, e := range o.GetManagedFields() {
+		if e.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(e.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		walkFields(fields, "", func(field string) {
+			if owners[field] == nil {
+				owners[field] = sets.String{}
+			}
+			owners[field].Insert(e.Manager)
+		})
+	}
+	return owners
+}
+
+// walkFields calls fn with the path of every field that the FieldsV1 set
+// fields marks as owned, e.g. .spec.containers[name=app].image.
+func walkFields(fields map[string]interface{}, prefix string, fn func(string)) {
+	if len(fields) == 0 && prefix != "" {
+		fn(prefix)
+		return
+	}
+	for k, v := range fields {
+		child, _ := v.(map[string]interface{})
+		switch {
+		case k == ".":
+			fn(prefix)
+		case strings.HasPrefix(k, "f:"):
+			walkFields(child, prefix+"."+k[2:], fn)
+		case strings.HasPrefix(k, "k:"):
+			var keys map[string]interface{}
+			json.Unmarshal([]byte(k[2:]), &keys)
+			var parts []string
+			for name, v := range keys {
+				parts = append(parts, fmt.Sprintf("%s=%v", name, v))
+			}
+			sort.Strings(parts)
+			walkFields(child, prefix+"["+strings.Join(parts, ",")+"]", fn)
+		case strings.HasPrefix(k, "v:"), strings.HasPrefix(k, "i:"):
+			walkFields(child, prefix+"["+k[2:]+"]", fn)
+		}
+	}
+}
+
+// ownershipTable lists the top-level fields of o that managedFields entries
+// own, each with its managers, operations and subresources, in aligned
+// columns.
+func ownershipTable(o *unstructured.Unstructured) []string {
+	owners := map[string][]string{}
+	for _, e := range o.GetManagedFields() {
+		if e.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(e.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		owner := e.Manager + " (" + string(e.Operation)
+		if e.Subresource != "" {
+			owner += ", " + e.Subresource
+		}
+		owner += ")"
+		for k := range fields {
+			if strings.HasPrefix(k, "f:") {
+				owners[k[2:]] = append(owners[k[2:]], owner)
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(owners))
+	for f := range owners {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tMANAGERS")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s\t%s\n", f, strings.Join(owners[f], ", "))
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yudai/gojsondiff"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// managedField returns a managedFields entry of manager owning fields, given
+// in FieldsV1 JSON, written at minute.
+func managedField(manager string, operation metav1.ManagedFieldsOperationType, subresource string, minute int, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:     manager,
+		Operation:   operation,
+		Subresource: subresource,
+		Time:        &metav1.Time{Time: time.Date(2019, 1, 1, 0, minute, 0, 0, time.UTC)},
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+// managedObject returns an object whose fields are owned by several managers,
+// kubectl twice, with change applied to it. The entries of the managers
+// named in updated, as manager or manager/subresource, are written a minute
+// later than the others.
+func managedObject(change func(o map[string]interface{}), updated ...string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "app"},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"paused":   false,
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:1"}},
+			}},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}}
+	if change != nil {
+		change(o.Object)
+	}
+	minute := func(manager string) int {
+		for _, u := range updated {
+			if u == manager {
+				return 1
+			}
+		}
+		return 0
+	}
+	o.SetManagedFields([]metav1.ManagedFieldsEntry{
+		managedField("helm", metav1.ManagedFieldsOperationApply, "", minute("helm"), `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`),
+		managedField("kubectl", metav1.ManagedFieldsOperationUpdate, "", minute("kubectl"), `{"f:spec":{"f:replicas":{}}}`),
+		managedField("kubectl", metav1.ManagedFieldsOperationUpdate, "scale", minute("kubectl/scale"), `{"f:spec":{"f:paused":{}}}`),
+		managedField("kube-controller-manager", metav1.ManagedFieldsOperationUpdate, "status", minute("kube-controller-manager/status"), `{"f:status":{"f:replicas":{}}}`),
+	})
+	return o
+}
+
+func setField(path []string, v interface{}) func(map[string]interface{}) {
+	return func(o map[string]interface{}) {
+		unstructured.SetNestedField(o, v, path...)
+	}
+}
+
+var managerTests = []struct {
+	name   string
+	change func(map[string]interface{})
+	// updated lists the entries rewritten by the change.
+	updated []string
+	want    []string
+}{
+	{"spec field", setField([]string{"spec", "replicas"}, int64(2)), []string{"kubectl"}, []string{"kubectl"}},
+	{"status subresource", setField([]string{"status", "replicas"}, int64(2)), []string{"kube-controller-manager/status"}, []string{"kube-controller-manager"}},
+	{"list element by key", func(o map[string]interface{}) {
+		o["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})["image"] = "app:2"
+	}, []string{"helm"}, []string{"helm"}},
+	{"several managers", func(o map[string]interface{}) {
+		setField([]string{"spec", "replicas"}, int64(2))(o)
+		setField([]string{"status", "replicas"}, int64(2))(o)
+	}, []string{"kubectl", "kube-controller-manager/status"}, []string{"kube-controller-manager", "kubectl"}},
+	// Removed fields are owned by no one, so the entries rewritten with
+	// them get the blame.
+	{"removed field", func(o map[string]interface{}) {
+		delete(o["spec"].(map[string]interface{}), "paused")
+	}, []string{"kubectl/scale"}, []string{"kubectl"}},
+}
+
+// diffedEvent returns the event processEvent makes of an update of old to
+// new.
+func diffedEvent(old, new *unstructured.Unstructured) *Event {
+	namespaceFilter = NewFilter(nil)
+	cache := newObjectCache(nil, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"})
+	processEvent(watch.Event{Type: watch.Added, Object: old}, cache)
+	return processEvent(watch.Event{Type: watch.Modified, Object: new}, cache)
+}
+
+func TestChangeManagers(t *testing.T) {
+	old := managedObject(nil)
+	for _, tt := range managerTests {
+		new := managedObject(tt.change, tt.updated...)
+		paths := changedPaths(gojsondiff.New().CompareObjects(old.Object, new.Object).Deltas())
+		if got := changeManagers(old, new, paths).List(); !sets.NewString(got...).Equal(sets.NewString(tt.want...)) {
+			t.Errorf("%s: change managers %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHumanChangesOnly(t *testing.T) {
+	defer func(v bool) { *humanChangesOnly = v }(*humanChangesOnly)
+	*humanChangesOnly = true
+
+	for _, tt := range managerTests {
+		e := diffedEvent(managedObject(nil), managedObject(tt.change, tt.updated...))
+		if want := matchManagers(sets.NewString(tt.want...), *humanManagers); (e != nil) != want {
+			t.Errorf("%s: printed %v, want %v", tt.name, e != nil, want)
+		}
+	}
+}
+
+func TestServerSideApplyOnly(t *testing.T) {
+	if _, err := Configure(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(v bool) { *serverSideApplyOnly = v }(*serverSideApplyOnly)
+	*serverSideApplyOnly = true
+
+	for _, tt := range managerTests {
+		old, new := managedObject(nil), managedObject(tt.change, tt.updated...)
+		d := newObjectDiff(old, new)
+		// Only helm applies its fields.
+		want := sets.NewString(tt.want...).Equal(sets.NewString("helm"))
+		if got := onlyAppliedChange(old, d.compared, d.paths); got != want {
+			t.Errorf("%s: only applied %v, want %v", tt.name, got, want)
+		}
+		if e := diffedEvent(old, new); (e != nil) != want {
+			t.Errorf("%s: printed %v, want %v", tt.name, e != nil, want)
+		}
+	}
+}
+
+func TestExcludeManagedBy(t *testing.T) {
+	if _, err := Configure(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(v []string) { *excludeManagedBy = v }(*excludeManagedBy)
+
+	// Changes are dropped only when all their managers are excluded.
+	for _, tt := range []struct {
+		excluded []string
+		dropped  sets.String
+	}{
+		{[]string{"kube-*"}, sets.NewString("status subresource")},
+		{[]string{"kube-*", "kubectl"}, sets.NewString("spec field", "status subresource", "several managers", "removed field")},
+	} {
+		*excludeManagedBy = tt.excluded
+		for _, c := range managerTests {
+			want := !tt.dropped.Has(c.name)
+			if e := diffedEvent(managedObject(nil), managedObject(c.change, c.updated...)); (e != nil) != want {
+				t.Errorf("--exclude-managed-by=%v: %s: printed %v, want %v", tt.excluded, c.name, e != nil, want)
+			}
+		}
+	}
+}
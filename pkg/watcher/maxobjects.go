@@ -0,0 +1,66 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/spf13/pflag"
+
+	"k8s.io/klog/v2"
+)
+
+var maxObjects = pflag.Int("max-objects", 0, "Print the events of only the first this many distinct objects, ignoring those of any other object once reached (0 means no limit)")
+
+// An objectLimit admits the events of the first --max-objects objects
+// printed. It is asked last, once every other filter kept an event, so that
+// objects all of whose events are dropped take no slot. It is used by the
+// printing goroutine only.
+type objectLimit struct {
+	max     int
+	seen    map[string]bool
+	reached bool
+}
+
+// newObjectLimit returns nil when --max-objects is not set.
+func newObjectLimit() *objectLimit {
+	if *maxObjects <= 0 {
+		return nil
+	}
+	return &objectLimit{max: *maxObjects, seen: map[string]bool{}}
+}
+
+// admits reports whether e is to be printed, tracking its object if it is
+// the first event of one and the limit is not reached yet.
+func (l *objectLimit) admits(e *Event) bool {
+	if l == nil {
+		return true
+	}
+	key := e.FullName()
+	switch {
+	case l.seen[key]:
+		return true
+	case len(l.seen) < l.max:
+		l.seen[key] = true
+		return true
+	}
+	if !l.reached {
+		l.reached = true
+		klog.Infof("printed events of %d objects, ignoring those of other objects from %s on", l.max, key)
+	} else {
+		klog.V(4).Infof("ignoring %s event of %s past --max-objects", e.Type, key)
+	}
+	return false
+}
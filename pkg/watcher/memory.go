@@ -0,0 +1,130 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/klog/v2"
+)
+
+var memoryLimit = pflag.Int("memory-limit", 0, "When the memory of the process nears this many MB, hold off watching further resources and compact the caches of the watched ones as --low-memory does (0 disables)")
+
+// memoryHighWater is the share of --memory-limit above which the memory is
+// under pressure.
+const memoryHighWater = 0.9
+
+// memory is the monitor of --memory-limit, nil without it.
+var memory *memoryMonitor
+
+// A memoryMonitor samples the memory of the process against a limit. The
+// caches are only touched by their watchers, so rather than compacting them
+// itself it signals pressure, and the watchers compact their own.
+type memoryMonitor struct {
+	limit uint64
+	mu    sync.Mutex
+	high  bool
+	// pressed is closed, and replaced, every time the memory is sampled
+	// under pressure.
+	pressed chan struct{}
+}
+
+func newMemoryMonitor(limitMB int) *memoryMonitor {
+	return &memoryMonitor{limit: uint64(limitMB) << 20, pressed: make(chan struct{})}
+}
+
+// pressure returns a channel closed the next time the memory is sampled
+// under pressure.
+func (m *memoryMonitor) pressure() <-chan struct{} {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pressed
+}
+
+// underPressure reports whether the memory was under pressure when last
+// sampled.
+func (m *memoryMonitor) underPressure() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.high
+}
+
+// waitForMemory blocks while the memory is under pressure, and reports
+// whether it got out of it before stopCh was closed.
+func (m *memoryMonitor) waitForMemory(what string, stopCh <-chan struct{}) bool {
+	if !m.underPressure() {
+		return true
+	}
+	klog.Warningf("memory near --memory-limit, holding off watching %s", what)
+	for m.underPressure() {
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(time.Second):
+		}
+	}
+	return true
+}
+
+// run samples the memory every second until stopCh is closed. The memory
+// is what the runtime holds from the OS, which is close to the RSS of the
+// process.
+func (m *memoryMonitor) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		used := stats.Sys - stats.HeapReleased
+		high := float64(used) >= memoryHighWater*float64(m.limit)
+
+		m.mu.Lock()
+		wasHigh := m.high
+		m.high = high
+		if high {
+			close(m.pressed)
+			m.pressed = make(chan struct{})
+		}
+		m.mu.Unlock()
+
+		switch {
+		case high && !wasHigh:
+			klog.Warningf("memory at %d MB of --memory-limit %d MB, compacting caches", used>>20, m.limit>>20)
+		case high:
+			// Give back what the caches freed since the last sample.
+			debug.FreeOSMemory()
+		case wasHigh:
+			klog.Infof("memory back to %d MB of --memory-limit %d MB", used>>20, m.limit>>20)
+		}
+	}
+}
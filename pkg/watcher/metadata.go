@@ -0,0 +1,139 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/metadata"
+)
+
+var metadataOnly = pflag.Bool("metadata-only", false, "Only fetch the metadata of objects (as PartialObjectMetadata), which saves bandwidth on large objects. "+
+	"Changes to labels, annotations, owner references, finalizers and generation are still shown, but changes to spec, status, data and any other top level field are not")
+
+// metadataClient returns the client used with --metadata-only, creating it
+// on first use.
+func (cl *Cluster) metadataClient() (metadata.Interface, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.metadata == nil {
+		c, err := metadata.NewForConfig(cl.cfg)
+		if err != nil {
+			return nil, err
+		}
+		cl.metadata = c
+	}
+	return cl.metadata, nil
+}
+
+func (cl *Cluster) metadataResource(gvr schema.GroupVersionResource) (*metadataResource, error) {
+	c, err := cl.metadataClient()
+	if err != nil {
+		return nil, err
+	}
+	kind, err := cl.kindOf(gvr)
+	if err != nil {
+		return nil, err
+	}
+	return &metadataResource{client: c.Resource(gvr), gvk: gvr.GroupVersion().WithKind(kind)}, nil
+}
+
+// kindOf returns the kind of gvr as reported by discovery.
+func (cl *Cluster) kindOf(gvr schema.GroupVersionResource) (string, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if kind, ok := cl.kinds[gvr]; ok {
+		return kind, nil
+	}
+	list, err := cl.disc.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return "", err
+	}
+	for _, r := range list.APIResources {
+		if r.Name == gvr.Resource {
+			if cl.kinds == nil {
+				cl.kinds = map[schema.GroupVersionResource]string{}
+			}
+			cl.kinds[gvr] = r.Kind
+			return r.Kind, nil
+		}
+	}
+	return "", fmt.Errorf("resource %v not found", gvr)
+}
+
+// metadataResource lists and watches the metadata of a resource, presenting
+// it as unstructured objects of the resource's kind.
+type metadataResource struct {
+	client metadata.ResourceInterface
+	gvk    schema.GroupVersionKind
+}
+
+func (r *metadataResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	objs, err := r.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	list.SetResourceVersion(objs.GetResourceVersion())
+	list.SetContinue(objs.GetContinue())
+	list.Items = make([]unstructured.Unstructured, 0, len(objs.Items))
+	for i := range objs.Items {
+		u, err := r.toUnstructured(&objs.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *u)
+	}
+	return list, nil
+}
+
+func (r *metadataResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := r.client.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		o, ok := e.Object.(*metav1.PartialObjectMetadata)
+		if !ok {
+			return e, true
+		}
+		u, err := r.toUnstructured(o)
+		if err != nil {
+			return watch.Event{Type: watch.Error, Object: &metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}}, true
+		}
+		e.Object = u
+		return e, true
+	}), nil
+}
+
+func (r *metadataResource) toUnstructured(o *metav1.PartialObjectMetadata) (*unstructured.Unstructured, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&o.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"metadata": m}}
+	u.SetGroupVersionKind(r.gvk)
+	return u, nil
+}
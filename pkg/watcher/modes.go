@@ -0,0 +1,318 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// A summarizer renders a concise description of the change from old to new.
+// old is empty for additions and new is empty for deletions. Returning an
+// empty string drops the event.
+type summarizer func(old, new *unstructured.Unstructured) string
+
+// A focusMode restricts watching to a few resources and prints summaries of
+// their changes instead of full diffs.
+type focusMode struct {
+	enabled     *bool
+	resources   []schema.GroupResource
+	summarizers map[schema.GroupKind]summarizer
+}
+
+var (
+	focusModes = []focusMode{
+		{
+			enabled:   pflag.Bool("watch-argocd", false, "Watch only Argo CD Applications and report their sync and health status transitions and the revision they are synced to"),
+			resources: []schema.GroupResource{{Group: argoGroup, Resource: "applications"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: argoGroup, Kind: "Application"}: summarizeApplication,
+			},
+		},
+		{
+			enabled: pflag.Bool("watch-certificates", false, "Watch only cert-manager Certificates and CertificateRequests and report their readiness, renewal and time to expiry"),
+			resources: []schema.GroupResource{
+				{Group: certManagerGroup, Resource: "certificates"},
+				{Group: certManagerGroup, Resource: "certificaterequests"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: certManagerGroup, Kind: "Certificate"}:        summarizeCertificate,
+				{Group: certManagerGroup, Kind: "CertificateRequest"}: summarizeCertificateRequest,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-crds", false, "Watch only CustomResourceDefinitions and summarize their changes"),
+			resources: []schema.GroupResource{{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: summarizeCRD,
+			},
+		},
+		{
+			enabled: pflag.Bool("watch-ingress", false, "Watch only Ingresses and Gateway API HTTPRoutes and summarize the routes they add and remove"),
+			resources: []schema.GroupResource{
+				{Group: networkingGroup, Resource: "ingresses"},
+				{Group: gatewayGroup, Resource: "httproutes"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: networkingGroup, Kind: "Ingress"}: summarizeIngress,
+				{Group: gatewayGroup, Kind: "HTTPRoute"}:  summarizeHTTPRoute,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-jobs", false, "Watch only Jobs and report their active, succeeded and failed counts and the conditions they reach"),
+			resources: []schema.GroupResource{{Group: batchGroup, Resource: "jobs"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: batchGroup, Kind: "Job"}: summarizeJob,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-rollouts", false, "Watch only Argo Rollouts and report the step, weight and phase of canaries, the ReplicaSets blue-green ones switch between, new revisions, pauses and aborts"),
+			resources: []schema.GroupResource{{Group: argoGroup, Resource: "rollouts"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: argoGroup, Kind: "Rollout"}: summarizeRollout,
+			},
+		},
+		{
+			enabled: pflag.Bool("watch-scaledobjects", false, "Watch only KEDA ScaledObjects and ScaledJobs and report when they pause, activate or go idle and changes to their replica bounds, triggers and metric health"),
+			resources: []schema.GroupResource{
+				{Group: kedaGroup, Resource: "scaledobjects"},
+				{Group: kedaGroup, Resource: "scaledjobs"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: kedaGroup, Kind: "ScaledObject"}: summarizeScaler,
+				{Group: kedaGroup, Kind: "ScaledJob"}:    summarizeScaler,
+			},
+		},
+		{
+			enabled:   watchNamespacesLifecycle,
+			resources: []schema.GroupResource{{Resource: "namespaces"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Kind: "Namespace"}: summarizeNamespace,
+			},
+		},
+		{
+			enabled: watchServicesEndpoints,
+			resources: []schema.GroupResource{
+				{Resource: "services"},
+				{Group: discoveryGroup, Resource: "endpointslices"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Kind: "Service"}: summarizeService,
+				endpointSliceKind: summarizeEndpointSlice,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-nodes-conditions", false, "Watch only Nodes and report transitions of their conditions"),
+			resources: []schema.GroupResource{{Resource: "nodes"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Kind: "Node"}: summarizeNodeConditions,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-hpa", false, "Watch only HorizontalPodAutoscalers and report their replica counts, metrics and condition changes"),
+			resources: []schema.GroupResource{{Group: autoscalingGroup, Resource: "horizontalpodautoscalers"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: autoscalingGroup, Kind: "HorizontalPodAutoscaler"}: summarizeHPA,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-pdb", false, "Watch only PodDisruptionBudgets and report the disruptions they allow, their healthy pods and the bounds they set, to tell when they would block draining nodes"),
+			resources: []schema.GroupResource{{Group: policyGroup, Resource: "poddisruptionbudgets"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: policyGroup, Kind: "PodDisruptionBudget"}: summarizePDB,
+			},
+		},
+		{
+			enabled:   pflag.Bool("watch-pods-restarts", false, "Watch only Pods and report their container restarts along with the reason and exit code of the last termination"),
+			resources: []schema.GroupResource{{Resource: "pods"}},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Kind: "Pod"}: summarizePodRestarts,
+			},
+		},
+		{
+			enabled: pflag.Bool("watch-pvc-binding", false, "Watch only PersistentVolumeClaims and PersistentVolumes and report their phase and binding changes"),
+			resources: []schema.GroupResource{
+				{Resource: "persistentvolumeclaims"},
+				{Resource: "persistentvolumes"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Kind: "PersistentVolumeClaim"}: summarizeStorageBinding("volume", claimVolume),
+				{Kind: "PersistentVolume"}:      summarizeStorageBinding("claim", volumeClaim),
+			},
+		},
+		{
+			enabled: pflag.Bool("watch-rbac", false, "Watch only RBAC roles and bindings and summarize the rules and subjects they grant"),
+			resources: []schema.GroupResource{
+				{Group: rbacGroup, Resource: "roles"},
+				{Group: rbacGroup, Resource: "rolebindings"},
+				{Group: rbacGroup, Resource: "clusterroles"},
+				{Group: rbacGroup, Resource: "clusterrolebindings"},
+			},
+			summarizers: map[schema.GroupKind]summarizer{
+				{Group: rbacGroup, Kind: "Role"}:               summarizeRole,
+				{Group: rbacGroup, Kind: "ClusterRole"}:        summarizeRole,
+				{Group: rbacGroup, Kind: "RoleBinding"}:        summarizeBinding,
+				{Group: rbacGroup, Kind: "ClusterRoleBinding"}: summarizeBinding,
+			},
+		},
+	}
+
+	summarizeAll = pflag.Bool("summarize", false, "Print summaries instead of diffs for every kind with a built-in summarizer, those of the --watch-* modes included, without restricting the watched resources")
+
+	// commonSummarizers summarize kinds no focus mode is about. Only
+	// --summarize enables them.
+	commonSummarizers = map[schema.GroupKind]summarizer{
+		{Kind: "Pod"}:                          summarizePod,
+		{Group: appsGroup, Kind: "Deployment"}: summarizeDeployment,
+	}
+
+	focusResources = map[schema.GroupResource]bool{}
+	summarizers    = map[schema.GroupKind]summarizer{}
+)
+
+// summarizerOf returns the summarizer of the changes of o, if any.
+func summarizerOf(o *unstructured.Unstructured) summarizer {
+	if *watchFinalizers {
+		return summarizeFinalizers
+	}
+	return summarizers[o.GroupVersionKind().GroupKind()]
+}
+
+func enableFocusModes() {
+	if *summarizeAll {
+		for _, m := range focusModes {
+			for gk, s := range m.summarizers {
+				summarizers[gk] = s
+			}
+		}
+		for gk, s := range commonSummarizers {
+			summarizers[gk] = s
+		}
+	}
+	// The summarizers of enabled modes take precedence over the others of
+	// their kinds.
+	for _, m := range focusModes {
+		if !*m.enabled {
+			continue
+		}
+		for _, r := range m.resources {
+			focusResources[r] = true
+		}
+		for gk, s := range m.summarizers {
+			summarizers[gk] = s
+		}
+	}
+}
+
+func summarizeCRD(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		group, _, _ := unstructured.NestedString(new.Object, "spec", "group")
+		return fmt.Sprintf("installed: group %s, served versions %s", group, servedVersions(new))
+	case len(new.Object) == 0:
+		group, _, _ := unstructured.NestedString(old.Object, "spec", "group")
+		return fmt.Sprintf("removed: group %s, served versions %s", group, servedVersions(old))
+	}
+
+	group, _, _ := unstructured.NestedString(new.Object, "spec", "group")
+	oldVersions, newVersions := servedVersions(old), servedVersions(new)
+	if oldVersions == newVersions {
+		return fmt.Sprintf("updated: group %s, served versions %s", group, newVersions)
+	}
+	return fmt.Sprintf("updated: group %s, served versions %s -> %s", group, oldVersions, newVersions)
+}
+
+func servedVersions(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var served []string
+	for _, v := range versions {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ok, _, _ := unstructured.NestedBool(m, "served"); !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(m, "name"); name != "" {
+			served = append(served, name)
+		}
+	}
+	if len(served) == 0 {
+		return "<none>"
+	}
+	return strings.Join(served, ",")
+}
+
+func summarizeNodeConditions(old, new *unstructured.Unstructured) string {
+	if len(new.Object) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, t := range conditionTransitions(old, new) {
+		lines = append(lines, fmt.Sprintf("node %s: %s", new.GetName(), t))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// conditionTransitions describes the status.conditions whose status differs
+// between old and new, e.g. "Ready True -> False (KubeletNotReady)".
+func conditionTransitions(old, new *unstructured.Unstructured) []string {
+	before := conditionsByType(old)
+	var transitions []string
+	for _, c := range conditions(new) {
+		typ, _, _ := unstructured.NestedString(c, "type")
+		status, _, _ := unstructured.NestedString(c, "status")
+		prev := "<none>"
+		if p, ok := before[typ]; ok {
+			prev, _, _ = unstructured.NestedString(p, "status")
+		}
+		if prev == status {
+			continue
+		}
+		t := fmt.Sprintf("%s %s -> %s", typ, prev, status)
+		if reason, _, _ := unstructured.NestedString(c, "reason"); reason != "" {
+			t += " (" + reason + ")"
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions
+}
+
+func conditions(o *unstructured.Unstructured) []map[string]interface{} {
+	list, _, _ := unstructured.NestedSlice(o.Object, "status", "conditions")
+	var conds []map[string]interface{}
+	for _, c := range list {
+		if m, ok := c.(map[string]interface{}); ok {
+			conds = append(conds, m)
+		}
+	}
+	return conds
+}
+
+func conditionsByType(o *unstructured.Unstructured) map[string]map[string]interface{} {
+	byType := map[string]map[string]interface{}{}
+	for _, c := range conditions(o) {
+		typ, _, _ := unstructured.NestedString(c, "type")
+		byType[typ] = c
+	}
+	return byType
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/spf13/pflag"
+)
+
+var excludeNames = pflag.StringSlice("exclude-name", nil, "Coma separated list of object name patterns whose events are dropped, e.g. *-canary,kube-root-ca.crt")
+
+func validateExcludeNames() error {
+	for _, p := range *excludeNames {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid --exclude-name pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// excludedName reports whether name matches one of --exclude-name.
+func excludedName(name string) bool {
+	for _, p := range *excludeNames {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+var (
+	maxNamespaces           = pflag.Int("max-namespaces", 0, "When no namespaces are given, stop processing objects from new namespaces once this many were seen (0 disables)")
+	excludeSystemNamespaces = pflag.Bool("exclude-system-namespaces", false, "Ignore objects in kube-system, kube-public, kube-node-lease and namespaces starting with --system-namespace-prefix")
+	systemNamespacePrefixes = pflag.StringSlice("system-namespace-prefix", nil, "Coma separated list of namespace prefixes considered infrastructure by --exclude-system-namespaces")
+	namespaceRegexes        = pflag.StringArray("namespace-regex", nil, "Also watch namespaces matching this regular expression, e.g. ^team- (may be repeated)")
+	excludeNamespaceRegexes = pflag.StringArray("exclude-namespace-regex", nil, "Ignore objects in namespaces matching this regular expression, e.g. ^ci- (may be repeated)")
+
+	systemNamespaces = sets.NewString("kube-system", "kube-public", "kube-node-lease")
+)
+
+// excludeSystem wraps filter to reject system namespaces and those starting
+// with one of prefixes.
+func excludeSystem(filter func(string) bool, prefixes []string) func(string) bool {
+	return func(ns string) bool {
+		if systemNamespaces.Has(ns) {
+			return false
+		}
+		for _, p := range prefixes {
+			if p != "" && strings.HasPrefix(ns, p) {
+				return false
+			}
+		}
+		return filter(ns)
+	}
+}
+
+// limitNamespaces wraps filter to admit objects from at most limit distinct
+// namespaces. Cluster scoped objects are not counted.
+func limitNamespaces(filter func(string) bool, limit int) func(string) bool {
+	var mu sync.Mutex
+	seen := sets.String{}
+	warned := false
+	return func(ns string) bool {
+		if ns == "" || !filter(ns) {
+			return filter(ns)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if seen.Has(ns) {
+			return true
+		}
+		if seen.Len() < limit {
+			seen.Insert(ns)
+			return true
+		}
+		if !warned {
+			klog.Warningf("seen more than %d namespaces, ignoring objects in new namespaces such as %q", limit, ns)
+			warned = true
+		}
+		return false
+	}
+}
+
+func compileRegexes(flag string, patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %q: %v", flag, p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// newNamespaceFilter is NewFilter(names) extended by --namespace-regex and
+// --exclude-namespace-regex. A namespace is watched if it is selected by
+// names or matches an inclusion regex, and is neither excluded by names nor
+// matches an exclusion regex.
+func newNamespaceFilter(names []string) (func(string) bool, error) {
+	include, err := compileRegexes("namespace-regex", *namespaceRegexes)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRegexes("exclude-namespace-regex", *excludeNamespaceRegexes)
+	if err != nil {
+		return nil, err
+	}
+	filter := NewFilter(names)
+	if len(include) == 0 && len(exclude) == 0 {
+		return filter, nil
+	}
+	var negated []string
+	hasIncludes := false
+	for _, name := range names {
+		if countPrefix(name, '!')%2 == 1 {
+			negated = append(negated, name)
+		} else {
+			hasIncludes = true
+		}
+	}
+	notExcluded := NewFilter(negated)
+	return func(ns string) bool {
+		for _, re := range exclude {
+			if re.MatchString(ns) {
+				return false
+			}
+		}
+		if len(include) == 0 {
+			return filter(ns)
+		}
+		for _, re := range include {
+			if re.MatchString(ns) {
+				return notExcluded(ns)
+			}
+		}
+		return hasIncludes && filter(ns)
+	}, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+
+	"k8s.io/klog/v2"
+)
+
+const notifyBurst = 5
+
+var (
+	notifyDeletesWebhook = pflag.String("notify-deletes-webhook", "", "Slack/Teams compatible incoming webhook URL notified of deleted objects")
+	notifyInterval       = pflag.Duration("notify-interval", 2*time.Second, fmt.Sprintf("Average interval between webhook notifications once a burst of %d is used up; deletions beyond that are summarized, at the latest on shutdown", notifyBurst))
+
+	deleteNotifier *webhookNotifier
+)
+
+// webhookNotifier posts {"text": ...} messages to an incoming webhook,
+// rate limiting them and summarizing the ones it had to hold back.
+type webhookNotifier struct {
+	url      string
+	interval time.Duration
+	limiter  *rate.Limiter
+	client   *http.Client
+	messages chan string
+	done     chan struct{}
+}
+
+func newWebhookNotifier(url string, interval time.Duration) *webhookNotifier {
+	return &webhookNotifier{
+		url:      url,
+		interval: interval,
+		limiter:  rate.NewLimiter(rate.Every(interval), notifyBurst),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		messages: make(chan string, 1000),
+		done:     make(chan struct{}),
+	}
+}
+
+func (n *webhookNotifier) notify(e *Event) {
+	o := e.Object
+	name := o.GetName()
+	if ns := o.GetNamespace(); ns != "" {
+		name = ns + "/" + name
+	}
+	msg := fmt.Sprintf("%s %s (%s) was deleted at %s", o.GetKind(), name, o.GetAPIVersion(), e.Timestamp.UTC().Format(time.RFC3339))
+	if e.Cluster != "" {
+		msg = "[" + e.Cluster + "] " + msg
+	}
+	select {
+	case n.messages <- msg:
+	default:
+		klog.Warning("dropping delete notification, webhook is falling behind: ", msg)
+	}
+}
+
+// run posts the notifications until close is called, then the summary of
+// the ones still held back.
+func (n *webhookNotifier) run() {
+	defer close(n.done)
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	suppressed := 0
+	for {
+		select {
+		case msg, ok := <-n.messages:
+			if !ok {
+				if suppressed != 0 {
+					n.post(fmt.Sprintf("%d more objects were deleted", suppressed))
+				}
+				return
+			}
+			if !n.limiter.Allow() {
+				suppressed++
+				continue
+			}
+			n.post(msg)
+		case <-ticker.C:
+			if suppressed != 0 && n.limiter.Allow() {
+				n.post(fmt.Sprintf("%d more objects were deleted", suppressed))
+				suppressed = 0
+			}
+		}
+	}
+}
+
+// close flushes the pending notifications once no more events are printed.
+func (n *webhookNotifier) close() {
+	close(n.messages)
+	<-n.done
+}
+
+func (n *webhookNotifier) post(text string) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		klog.Error("error encoding notification: ", err)
+		return
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Error("error posting notification: ", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		klog.Errorf("error posting notification: %s", resp.Status)
+	}
+}
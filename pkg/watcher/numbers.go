@@ -0,0 +1,125 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var numbersAsStrings = pflag.Bool("diff-numbers-as-strings", false, "Compare numbers by value, so that e.g. 3 and 3.0 compare equal, and so do a number and a string holding the same value, like 3 and \"3\". Two strings are still compared as written")
+
+// diffForm returns the content of o to diff, which with --semantic-quantities
+// or --decode-last-applied is a copy with its quantities normalized or its
+// last applied configuration decoded. The object itself is left alone for
+// the filters and summarizers reading its fields.
+func diffForm(o *unstructured.Unstructured) map[string]interface{} {
+	if !*numbersAsStrings && !*semanticQuantities && !*decodeLastApplied {
+		return o.Object
+	}
+	m := runtime.DeepCopyJSON(o.Object)
+	if *decodeLastApplied {
+		decodeLastAppliedConfig(m)
+	}
+	if *semanticQuantities {
+		normalizeQuantities(m, o.GetKind() == "ResourceQuota")
+	}
+	return m
+}
+
+// diffForms returns the diff forms of old and new. With
+// --diff-numbers-as-strings, the values of new equal to those of old as
+// numbers are made the same as in old.
+func diffForms(old, new *unstructured.Unstructured) (map[string]interface{}, map[string]interface{}) {
+	base, target := diffForm(old), diffForm(new)
+	if *numbersAsStrings {
+		equateNumbers(base, target)
+	}
+	return base, target
+}
+
+// equateNumbers returns b, with the values equal to those of a as numbers
+// replaced by those of a. Only numbers are compared to strings, two strings
+// differ whenever they are written differently, like "1.10" and "1.1".
+func equateNumbers(a, b interface{}) interface{} {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			for key, value := range b {
+				if old, ok := a[key]; ok {
+					b[key] = equateNumbers(old, value)
+				}
+			}
+		}
+		return b
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			for i := range b {
+				if i < len(a) {
+					b[i] = equateNumbers(a[i], b[i])
+				}
+			}
+		}
+		return b
+	}
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if aString && bString {
+		return b
+	}
+	if x, ok := numberString(a); ok {
+		if y, ok := numberString(b); ok && x == y {
+			return a
+		}
+	}
+	return b
+}
+
+// numberString returns the shortest string representing the value of v, if v
+// is a number or a string holding a plain decimal number.
+func numberString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return canonicalNumber(string(v))
+	case string:
+		return canonicalNumber(v)
+	}
+	return "", false
+}
+
+// canonicalNumber returns s in canonical form if it is a plain decimal number.
+func canonicalNumber(s string) (string, bool) {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return "", false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return strconv.FormatInt(n, 10), true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+
+	"github.com/yudai/gojsondiff"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffNumbersAsStrings(t *testing.T) {
+	defer func(v bool) { *numbersAsStrings = v }(*numbersAsStrings)
+	*numbersAsStrings = true
+
+	for _, tc := range []struct {
+		old, new interface{}
+		changed  bool
+	}{
+		{int64(3), float64(3), false},
+		{int64(3), "3", false},
+		{"3", float64(3), false},
+		{"3.0", int64(3), false},
+		{int64(3), int64(4), true},
+		{int64(3), "4", true},
+		{"1.10", "1.1", true},
+		{"007", "7", true},
+		{"1e3", "1000", true},
+		{"v1.10", "v1.1", true},
+	} {
+		obj := func(v interface{}) *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"data": map[string]interface{}{"value": v},
+				"list": []interface{}{v},
+			}}
+		}
+		old, new := obj(tc.old), obj(tc.new)
+		if changed := gojsondiff.New().CompareObjects(diffForms(old, new)).Modified(); changed != tc.changed {
+			t.Errorf("%#v -> %#v: changed = %v, want %v", tc.old, tc.new, changed, tc.changed)
+		}
+		if new.Object["data"].(map[string]interface{})["value"] != tc.new {
+			t.Errorf("%#v -> %#v: the object itself was modified", tc.old, tc.new)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	objectFormat = pflag.String("object-format", "yaml", "Serialization of whole objects in output modes that print them: yaml or json")
+	dumpInitial  = pflag.Bool("dump-initial", false, "Print every listed object matching the filters in full, serialized as --object-format, ahead of the initial sync marker")
+	fullOnDelete = pflag.Bool("full-on-delete", false, "Print the last known state of deleted objects in full, serialized as --object-format, instead of their diff")
+)
+
+func validateObjectFormat() error {
+	switch *objectFormat {
+	case "yaml", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown object format %q", *objectFormat)
+}
+
+// formatObject serializes o for display according to --object-format.
+func formatObject(o *unstructured.Unstructured) (string, error) {
+	if *objectFormat == "json" {
+		data, err := json.MarshalIndent(o.Object, "", "  ")
+		return string(data), err
+	}
+	data, err := yaml.Marshal(o.Object)
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+// deletedObjectEvent returns the event of the deletion of o printing o in
+// full.
+func deletedObjectEvent(now time.Time, key string, o *unstructured.Unstructured) *Event {
+	text, err := formatObject(o)
+	if err != nil {
+		klog.Error("error encoding object: ", err)
+		return nil
+	}
+	return &Event{Timestamp: now, Name: key, Data: text + "\n", Type: watch.Deleted, Object: o, Old: o}
+}
+
+// snapshotEvents returns the events printing the objects in cache in full for
+// --dump-initial, sorted by key.
+func snapshotEvents(cache *objectCache) []*Event {
+	keys := cache.keys()
+	sort.Strings(keys)
+	now := Now()
+	var events []*Event
+	for _, key := range keys {
+		o, ok := cache.get(key)
+		if !ok || excludedName(o.GetName()) || !matchesWhere(o) {
+			continue
+		}
+		if e := snapshotEvent(now, key, o); e != nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// snapshotEvent returns an Added event printing o in full.
+func snapshotEvent(now time.Time, key string, o *unstructured.Unstructured) *Event {
+	text, err := formatObject(o)
+	if err != nil {
+		klog.Error("error encoding object: ", err)
+		return nil
+	}
+	return &Event{Timestamp: now, Name: key, Data: text + "\n", Type: watch.Added, Object: o}
+}
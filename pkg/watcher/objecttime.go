@@ -0,0 +1,62 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var objectTime = pflag.Bool("object-time", false, "Stamp additions and updates with the latest time recorded in the object (managedFields and status conditions) instead of when they were received")
+
+// eventTime returns the time to stamp an event of o received at now with.
+// Deletions aren't timed by the server, so they keep now, as does anything
+// without timestamps or with one from the future due to clock skew.
+func eventTime(t watch.EventType, o *unstructured.Unstructured, now time.Time) time.Time {
+	if !*objectTime || t == watch.Deleted {
+		return now
+	}
+	latest := lastModified(o)
+	if latest.IsZero() || latest.After(now) {
+		return now
+	}
+	return latest
+}
+
+// lastModified returns the latest time recorded in o: its creation, the
+// managedFields updates and status condition transitions.
+func lastModified(o *unstructured.Unstructured) time.Time {
+	latest := o.GetCreationTimestamp().Time
+	for _, f := range o.GetManagedFields() {
+		if f.Time != nil && f.Time.After(latest) {
+			latest = f.Time.Time
+		}
+	}
+	for _, c := range conditions(o) {
+		for _, field := range []string{"lastTransitionTime", "lastUpdateTime"} {
+			s, _, _ := unstructured.NestedString(c, field)
+			if ts, err := time.Parse(time.RFC3339, s); err == nil && ts.After(latest) {
+				latest = ts
+			}
+		}
+	}
+	return latest
+}
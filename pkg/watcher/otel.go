@@ -0,0 +1,229 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+const (
+	otelBatchSize     = 100
+	otelFlushInterval = time.Second
+)
+
+var (
+	otelEndpoint = pflag.String("otel-endpoint", "", "Export every event as an OpenTelemetry span to this OTLP/HTTP collector, e.g. http://localhost:4318")
+
+	spanExporter *otelExporter
+)
+
+// The types below are the parts of the OTLP/JSON encoding of traces that are
+// used.
+
+type otelTraces struct {
+	ResourceSpans []otelResourceSpans `json:"resourceSpans"`
+}
+
+type otelResourceSpans struct {
+	Resource   otelResource     `json:"resource"`
+	ScopeSpans []otelScopeSpans `json:"scopeSpans"`
+}
+
+type otelResource struct {
+	Attributes []otelAttribute `json:"attributes"`
+}
+
+type otelScopeSpans struct {
+	Scope otelScope  `json:"scope"`
+	Spans []otelSpan `json:"spans"`
+}
+
+type otelScope struct {
+	Name string `json:"name"`
+}
+
+type otelSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otelAttribute `json:"attributes"`
+}
+
+type otelAttribute struct {
+	Key   string    `json:"key"`
+	Value otelValue `json:"value"`
+}
+
+type otelValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	ArrayValue  *otelArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otelArrayValue struct {
+	Values []otelValue `json:"values"`
+}
+
+func stringAttribute(key, value string) otelAttribute {
+	return otelAttribute{Key: key, Value: otelValue{StringValue: &value}}
+}
+
+// spanKindInternal is SPAN_KIND_INTERNAL.
+const spanKindInternal = 1
+
+// An otelExporter posts events as spans to {endpoint}/v1/traces in batches,
+// from a goroutine of its own. Events are dropped while it falls behind.
+type otelExporter struct {
+	url    string
+	client *http.Client
+	spans  chan otelSpan
+	done   sync.WaitGroup
+}
+
+func newOtelExporter(endpoint string) *otelExporter {
+	x := &otelExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan otelSpan, 1000),
+	}
+	x.done.Add(1)
+	go x.run()
+	return x
+}
+
+// export queues a zero length span of e. All the spans of an object share
+// the trace named by its UID.
+func (x *otelExporter) export(e *Event) {
+	o := e.Object
+	ts := strconv.FormatInt(e.Timestamp.UnixNano(), 10)
+	verb := auditVerbs[e.Type]
+	span := otelSpan{
+		TraceID:           traceID(string(o.GetUID())),
+		SpanID:            randomHex(8),
+		Name:              verb + " " + o.GetKind(),
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: ts,
+		EndTimeUnixNano:   ts,
+		Attributes: []otelAttribute{
+			stringAttribute("k8s.namespace.name", o.GetNamespace()),
+			stringAttribute("k8s.object.kind", o.GetKind()),
+			stringAttribute("k8s.object.name", o.GetName()),
+			stringAttribute("k8s.object.api_version", o.GetAPIVersion()),
+			stringAttribute("kubectl_watch.verb", verb),
+			stringAttribute("kubectl_watch.seq", strconv.FormatUint(e.Seq, 10)),
+		},
+	}
+	if e.Cluster != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("k8s.cluster.name", e.Cluster))
+	}
+	if len(e.Paths) > 0 {
+		paths := &otelArrayValue{}
+		for _, p := range e.Paths {
+			s := p.String()
+			paths.Values = append(paths.Values, otelValue{StringValue: &s})
+		}
+		span.Attributes = append(span.Attributes, otelAttribute{Key: "kubectl_watch.paths", Value: otelValue{ArrayValue: paths}})
+	}
+	select {
+	case x.spans <- span:
+	default:
+		klog.Warning("dropping span, OpenTelemetry collector is falling behind")
+	}
+}
+
+// Close waits for the queued spans to be posted.
+func (x *otelExporter) Close() error {
+	close(x.spans)
+	x.done.Wait()
+	return nil
+}
+
+func (x *otelExporter) run() {
+	defer x.done.Done()
+	ticker := time.NewTicker(otelFlushInterval)
+	defer ticker.Stop()
+	var batch []otelSpan
+	for {
+		select {
+		case span, ok := <-x.spans:
+			if !ok {
+				x.post(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) < otelBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		x.post(batch)
+		batch = nil
+	}
+}
+
+func (x *otelExporter) post(spans []otelSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(otelTraces{ResourceSpans: []otelResourceSpans{{
+		Resource:   otelResource{Attributes: []otelAttribute{stringAttribute("service.name", "kubectl-watch")}},
+		ScopeSpans: []otelScopeSpans{{Scope: otelScope{Name: "kubectl-watch"}, Spans: spans}},
+	}}})
+	if err != nil {
+		klog.Error("error encoding spans: ", err)
+		return
+	}
+	resp, err := x.client.Post(x.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Error("error exporting spans: ", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		klog.Errorf("error exporting spans: %s", resp.Status)
+	}
+}
+
+// traceID returns the hex encoded trace ID of the object with uid, which is
+// the UID itself if it is a UUID, else a random one.
+func traceID(uid string) string {
+	if id := strings.ReplaceAll(uid, "-", ""); len(id) == 32 {
+		if _, err := hex.DecodeString(id); err == nil {
+			return strings.ToLower(id)
+		}
+	}
+	return randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
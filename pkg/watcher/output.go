@@ -0,0 +1,88 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var outFile = pflag.String("out-file", "", "Write output to this file instead of stdout, gzip compressed if it ends in .gz")
+
+// openOutputFile creates path for writing. Paths ending in .gz get a gzip
+// stream that is flushed after every write, so an interrupted capture is
+// still readable up to its last event.
+func openOutputFile(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	return &gzipFile{f, gzip.NewWriter(f)}, nil
+}
+
+type gzipFile struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+func (g *gzipFile) Write(p []byte) (int, error) {
+	n, err := g.gz.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, g.gz.Flush()
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.gz.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// A pipeWriter stops the watch once the reader of the output goes away, e.g.
+// when piped to head, and discards everything written afterwards so that no
+// further SIGPIPE is raised.
+type pipeWriter struct {
+	w      io.Writer
+	closed bool
+}
+
+func (p *pipeWriter) Write(b []byte) (int, error) {
+	if p.closed {
+		return len(b), nil
+	}
+	n, err := p.w.Write(b)
+	if errors.Is(err, syscall.EPIPE) {
+		klog.V(2).Info("output closed, stopping")
+		p.closed = true
+		stop(0)
+		return len(b), nil
+	}
+	return n, err
+}
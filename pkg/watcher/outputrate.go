@@ -0,0 +1,135 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+)
+
+// maxDeferredEvents bounds the events held back by --output-rate-policy
+// defer, beyond which they are dropped.
+const maxDeferredEvents = 10000
+
+var (
+	maxOutputRate    = pflag.Float64("max-output-rate", 0, "Print at most this many events per second in total, dropping or deferring the rest as --output-rate-policy says (0 disables)")
+	outputRatePolicy = pflag.String("output-rate-policy", "drop", "What to do with events over --max-output-rate: drop them or defer them until the rate allows")
+	outputRateReport = pflag.Duration("output-rate-report", 10*time.Second, "How often to print how many events --max-output-rate dropped or deferred")
+)
+
+func validateOutputRate() error {
+	switch *outputRatePolicy {
+	case "drop", "defer":
+		return nil
+	}
+	return fmt.Errorf("unknown --output-rate-policy %q, expected drop or defer", *outputRatePolicy)
+}
+
+// An outputLimiter caps the rate of printed events. It is used by a single
+// goroutine.
+type outputLimiter struct {
+	limiter  *rate.Limiter
+	interval time.Duration
+	deferred []*Event
+	// dropped and delayed count the events dropped and deferred since the
+	// last report.
+	dropped, delayed int
+}
+
+// newOutputLimiter returns nil when --max-output-rate is not set.
+func newOutputLimiter() *outputLimiter {
+	if *maxOutputRate <= 0 {
+		return nil
+	}
+	return &outputLimiter{
+		limiter:  rate.NewLimiter(rate.Limit(*maxOutputRate), max(int(*maxOutputRate), 1)),
+		interval: time.Duration(float64(time.Second) / *maxOutputRate),
+	}
+}
+
+// add returns the events to print now in response to e.
+func (l *outputLimiter) add(e *Event) []*Event {
+	if l == nil {
+		return []*Event{e}
+	}
+	if len(l.deferred) == 0 && l.limiter.Allow() {
+		return []*Event{e}
+	}
+	if *outputRatePolicy == "drop" || len(l.deferred) >= maxDeferredEvents {
+		l.dropped++
+		return nil
+	}
+	l.deferred = append(l.deferred, e)
+	l.delayed++
+	return nil
+}
+
+// timer returns a channel that fires when deferred events may be printed, or
+// nil if there are none.
+func (l *outputLimiter) timer() <-chan time.Time {
+	if l == nil || len(l.deferred) == 0 {
+		return nil
+	}
+	return time.After(l.interval)
+}
+
+// due returns the deferred events the rate allows to print now.
+func (l *outputLimiter) due() []*Event {
+	n := 0
+	for n < len(l.deferred) && l.limiter.Allow() {
+		n++
+	}
+	events := l.deferred[:n]
+	l.deferred = l.deferred[n:]
+	return events
+}
+
+// flush returns all deferred events.
+func (l *outputLimiter) flush() []*Event {
+	if l == nil {
+		return nil
+	}
+	events := l.deferred
+	l.deferred = nil
+	return events
+}
+
+// reports returns a channel that fires every --output-rate-report, or nil if
+// output is not limited.
+func (l *outputLimiter) reports() <-chan time.Time {
+	if l == nil || *outputRateReport <= 0 {
+		return nil
+	}
+	return time.NewTicker(*outputRateReport).C
+}
+
+// report describes the events dropped and deferred since the last call, or
+// returns "" if there were none.
+func (l *outputLimiter) report() string {
+	if l.dropped == 0 && l.delayed == 0 {
+		return ""
+	}
+	msg := fmt.Sprintf("over --max-output-rate: %d events dropped", l.dropped)
+	if *outputRatePolicy == "defer" {
+		msg = fmt.Sprintf("over --max-output-rate: %d events deferred, %d waiting, %d dropped", l.delayed, len(l.deferred), l.dropped)
+	}
+	l.dropped, l.delayed = 0, 0
+	return msg
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var highlightOwnership = pflag.Bool("highlight-ownership", false, "Summarize changes to ownerReferences above the diff, e.g. \"adopted by ReplicaSet/foo-abc\"")
+
+// ownershipChanges describes the owners added to and removed from new
+// compared to old.
+func ownershipChanges(old, new *unstructured.Unstructured) []string {
+	before := map[types.UID]bool{}
+	for _, r := range old.GetOwnerReferences() {
+		before[r.UID] = true
+	}
+	after := map[types.UID]bool{}
+	var changes []string
+	for _, r := range new.GetOwnerReferences() {
+		after[r.UID] = true
+		if !before[r.UID] {
+			changes = append(changes, "adopted by "+r.Kind+"/"+r.Name)
+		}
+	}
+	for _, r := range old.GetOwnerReferences() {
+		if !after[r.UID] {
+			changes = append(changes, "orphaned from "+r.Kind+"/"+r.Name)
+		}
+	}
+	return changes
+}
+
+// highlightOwnershipChanges prefixes text with a line per ownership change.
+func highlightOwnershipChanges(text string, old, new *unstructured.Unstructured, paths []fieldPath) string {
+	touched := false
+	for _, p := range paths {
+		if p.hasPrefix("metadata", "ownerReferences") {
+			touched = true
+			break
+		}
+	}
+	if !touched {
+		return text
+	}
+	return prependNotes(text, ownershipChanges(old, new))
+}
+
+// prependNotes prefixes text with one highlighted line per note.
+func prependNotes(text string, notes []string) string {
+	var buf strings.Builder
+	for _, n := range notes {
+		buf.WriteString(colorText(n, noteStyle))
+		buf.WriteByte('\n')
+	}
+	return buf.String() + text
+}
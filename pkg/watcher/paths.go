@@ -0,0 +1,182 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yudai/gojsondiff"
+)
+
+// A fieldPath locates a value within an object. Its elements are either
+// strings (object keys) or ints (array indices).
+type fieldPath []interface{}
+
+func (p fieldPath) String() string {
+	var buf strings.Builder
+	for _, e := range p {
+		switch e := e.(type) {
+		case string:
+			buf.WriteByte('.')
+			buf.WriteString(e)
+		case int:
+			buf.WriteByte('[')
+			buf.WriteString(strconv.Itoa(e))
+			buf.WriteByte(']')
+		}
+	}
+	return buf.String()
+}
+
+// valueIn returns the value at p within obj.
+func (p fieldPath) valueIn(obj interface{}) (interface{}, bool) {
+	for _, e := range p {
+		switch e := e.(type) {
+		case string:
+			m, ok := obj.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if obj, ok = m[e]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := obj.([]interface{})
+			if !ok || e >= len(a) {
+				return nil, false
+			}
+			obj = a[e]
+		}
+	}
+	return obj, true
+}
+
+// hasPrefix reports whether p is within the subtree rooted at the given keys.
+func (p fieldPath) hasPrefix(keys ...string) bool {
+	if len(p) < len(keys) {
+		return false
+	}
+	for i, k := range keys {
+		if s, ok := p[i].(string); !ok || s != k {
+			return false
+		}
+	}
+	return true
+}
+
+// isBookkeepingPath reports whether p is maintained by the API server on every
+// write rather than describing an actual change.
+func isBookkeepingPath(p fieldPath) bool {
+	return p.hasPrefix("metadata", "resourceVersion") || p.hasPrefix("metadata", "managedFields")
+}
+
+// isStatusOnly reports whether all paths besides bookkeeping are under status.
+func isStatusOnly(paths []fieldPath) bool {
+	status := false
+	for _, p := range paths {
+		switch {
+		case p.hasPrefix("status"):
+			status = true
+		case !isBookkeepingPath(p):
+			return false
+		}
+	}
+	return status
+}
+
+// isNoopUpdate reports whether paths only record that the object was written
+// again: a new resourceVersion and refreshed managedFields timestamps.
+func isNoopUpdate(paths []fieldPath) bool {
+	for _, p := range paths {
+		if p.hasPrefix("metadata", "resourceVersion") {
+			continue
+		}
+		if p.hasPrefix("metadata", "managedFields") && len(p) == 4 && p[3] == "time" {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// changedPaths returns the paths of all leaf changes in deltas.
+func changedPaths(deltas []gojsondiff.Delta) []fieldPath {
+	return appendChangedPaths(nil, nil, deltas)
+}
+
+func appendChangedPaths(paths []fieldPath, prefix fieldPath, deltas []gojsondiff.Delta) []fieldPath {
+	for _, d := range deltas {
+		var pos gojsondiff.Position
+		switch d := d.(type) {
+		case gojsondiff.PostDelta:
+			pos = d.PostPosition()
+		case gojsondiff.PreDelta:
+			pos = d.PrePosition()
+		}
+
+		path := append(fieldPath{}, prefix...)
+		switch pos := pos.(type) {
+		case gojsondiff.Name:
+			path = append(path, string(pos))
+		case gojsondiff.Index:
+			path = append(path, int(pos))
+		}
+
+		switch d := d.(type) {
+		case *gojsondiff.Object:
+			paths = appendChangedPaths(paths, path, d.Deltas)
+		case *gojsondiff.Array:
+			paths = appendChangedPaths(paths, path, d.Deltas)
+		default:
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// A diffSummary counts the leaf changes of a diff by kind.
+type diffSummary struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+}
+
+// summarizeDeltas counts the leaf changes in deltas. Values moved within
+// arrays count as modified.
+func summarizeDeltas(deltas []gojsondiff.Delta) *diffSummary {
+	s := &diffSummary{}
+	s.add(deltas)
+	return s
+}
+
+func (s *diffSummary) add(deltas []gojsondiff.Delta) {
+	for _, d := range deltas {
+		switch d := d.(type) {
+		case *gojsondiff.Object:
+			s.add(d.Deltas)
+		case *gojsondiff.Array:
+			s.add(d.Deltas)
+		case *gojsondiff.Added:
+			s.Added++
+		case *gojsondiff.Deleted:
+			s.Removed++
+		default:
+			s.Modified++
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const policyGroup = "policy"
+
+// summarizePDB reports the disruptions a PodDisruptionBudget allows along
+// with its healthy pods, e.g. "pdb web: allowed 1 -> 0 (3/3 healthy)", which
+// tells when it would block draining a node, and changes of its bounds.
+func summarizePDB(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: %s, allowed %s (%s healthy)", pdbBounds(new), hpaInt(new, "status", "disruptionsAllowed"), pdbHealthy(new))
+	case len(new.Object) == 0:
+		return "deleted"
+	}
+	var lines []string
+	before, after := hpaInt(old, "status", "disruptionsAllowed"), hpaInt(new, "status", "disruptionsAllowed")
+	switch {
+	case before != after:
+		l := fmt.Sprintf("pdb %s: allowed %s -> %s (%s healthy)", new.GetName(), before, after, pdbHealthy(new))
+		if after == "0" {
+			l += ", blocking evictions"
+		}
+		lines = append(lines, l)
+	case pdbHealthy(old) != pdbHealthy(new):
+		lines = append(lines, fmt.Sprintf("pdb %s: healthy %s -> %s (allowed %s)", new.GetName(), pdbHealthy(old), pdbHealthy(new), after))
+	}
+	if before, after := pdbBounds(old), pdbBounds(new); before != after {
+		lines = append(lines, before+" -> "+after)
+	}
+	lines = append(lines, conditionTransitions(old, new)...)
+	return strings.Join(lines, "\n")
+}
+
+// pdbHealthy describes the current and desired healthy pods of a
+// PodDisruptionBudget, e.g. "2/3".
+func pdbHealthy(pdb *unstructured.Unstructured) string {
+	return hpaInt(pdb, "status", "currentHealthy") + "/" + hpaInt(pdb, "status", "desiredHealthy")
+}
+
+// pdbBounds describes the bound set by a PodDisruptionBudget, e.g.
+// "minAvailable 2" or "maxUnavailable 10%".
+func pdbBounds(pdb *unstructured.Unstructured) string {
+	for _, field := range []string{"minAvailable", "maxUnavailable"} {
+		if v, ok, _ := unstructured.NestedFieldNoCopy(pdb.Object, "spec", field); ok {
+			return fmt.Sprintf("%s %v", field, v)
+		}
+	}
+	return "no bounds"
+}
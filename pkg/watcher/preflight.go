@@ -0,0 +1,88 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var preflightRBAC = pflag.Bool("preflight-rbac", false, "Check with SelfSubjectAccessReviews that every resource may be listed and watched before watching it, and skip those that may not with a summary once synced instead of an error each")
+
+var (
+	deniedMu sync.Mutex
+	// denied lists the resources skipped by --preflight-rbac by cluster.
+	denied = map[string][]string{}
+)
+
+// accessAllowed reports whether the user of cl may list and watch gvr in all
+// namespaces, assuming it may if that cannot be reviewed.
+func accessAllowed(cl *Cluster, gvr schema.GroupVersionResource) bool {
+	if !*preflightRBAC || cl.client == nil {
+		return true
+	}
+	for _, verb := range []string{"list", "watch"} {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:     verb,
+					Group:    gvr.Group,
+					Version:  gvr.Version,
+					Resource: gvr.Resource,
+				},
+			},
+		}
+		r, err := cl.client.AuthorizationV1().SelfSubjectAccessReviews().Create(context.Background(), review, metav1.CreateOptions{})
+		if err != nil {
+			klog.V(2).Infof("error reviewing access to '%v': %v", gvr, err)
+			return true
+		}
+		if !r.Status.Allowed {
+			klog.V(4).Infof("skipping '%v', %s denied: %s", gvr, verb, r.Status.Reason)
+			deniedMu.Lock()
+			denied[cl.name] = append(denied[cl.name], gvr.GroupResource().String())
+			deniedMu.Unlock()
+			return false
+		}
+	}
+	return true
+}
+
+// reportDenied logs the resources skipped by --preflight-rbac.
+func reportDenied() {
+	deniedMu.Lock()
+	defer deniedMu.Unlock()
+	clusters := make([]string, 0, len(denied))
+	for name := range denied {
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+	for _, name := range clusters {
+		resources := denied[name]
+		sort.Strings(resources)
+		klog.Warningf("skipped %d resources of cluster %s that cannot be listed and watched: %s", len(resources), name, strings.Join(resources, ", "))
+	}
+}
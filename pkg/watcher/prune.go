@@ -0,0 +1,84 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	specOnly              = pflag.Bool("spec-only", false, "Only diff the desired state of objects, ignoring status and metadata besides their name")
+	labelsAnnotationsOnly = pflag.Bool("labels-annotations-only", false, "Only diff metadata.labels and metadata.annotations, ignoring all other changes")
+)
+
+// copyObject returns a deep copy of o to diff and cache. With --spec-only
+// or --labels-annotations-only only the watched subtrees and the name of the
+// object are copied, which matters for large objects with busy status. Raw
+// output and summarized kinds always get the whole object.
+func copyObject(o *unstructured.Unstructured) *unstructured.Unstructured {
+	if !*specOnly && !*labelsAnnotationsOnly || *rawOutput != "" || summarizers[o.GroupVersionKind().GroupKind()] != nil {
+		return o.DeepCopy()
+	}
+
+	pruned := make(map[string]interface{}, len(o.Object))
+	if *specOnly {
+		for k, v := range o.Object {
+			if k != "metadata" && k != "status" {
+				pruned[k] = runtime.DeepCopyJSONValue(v)
+			}
+		}
+	} else {
+		pruned["apiVersion"] = o.Object["apiVersion"]
+		pruned["kind"] = o.Object["kind"]
+	}
+	keep := []string{"name", "namespace", "uid"}
+	if *generationOnly {
+		keep = append(keep, "generation")
+	}
+	if *labelsAnnotationsOnly {
+		keep = append(keep, "labels", "annotations")
+	} else if len(*showLabels) != 0 {
+		keep = append(keep, "labels")
+	}
+	if *traceGC {
+		keep = append(keep, "ownerReferences")
+	}
+	if *humanChangesOnly || *serverSideApplyOnly || len(*excludeManagedBy) != 0 || *showManagerDiff || *showOwnershipTable {
+		keep = append(keep, "managedFields")
+	}
+	metadata, _ := o.Object["metadata"].(map[string]interface{})
+	copied := make(map[string]interface{}, len(keep))
+	for _, k := range keep {
+		if v, ok := metadata[k]; ok {
+			copied[k] = runtime.DeepCopyJSONValue(v)
+		}
+	}
+	pruned["metadata"] = copied
+	return &unstructured.Unstructured{Object: pruned}
+}
+
+func touchesLabelsOrAnnotations(paths []fieldPath) bool {
+	for _, p := range paths {
+		if p.hasPrefix("metadata", "labels") || p.hasPrefix("metadata", "annotations") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var semanticQuantities = pflag.Bool("semantic-quantities", false, "Compare resource requests and limits, and ResourceQuota amounts, by value, so that e.g. 100m and 0.1 or 1Gi and 1073741824 compare equal")
+
+// normalizeQuantities rewrites the quantities in the requests and limits of
+// every resources field of obj, and the hard and used amounts of quotas, as
+// plain decimal numbers. Values that don't parse are left alone.
+func normalizeQuantities(obj map[string]interface{}, quota bool) {
+	for key, value := range obj {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if key == "resources" {
+				for _, k := range []string{"requests", "limits"} {
+					if m, ok := value[k].(map[string]interface{}); ok {
+						canonicalizeQuantities(m)
+					}
+				}
+			}
+			if quota && (key == "hard" || key == "used") {
+				canonicalizeQuantities(value)
+			}
+			normalizeQuantities(value, quota)
+		case []interface{}:
+			for _, item := range value {
+				if m, ok := item.(map[string]interface{}); ok {
+					normalizeQuantities(m, quota)
+				}
+			}
+		}
+	}
+}
+
+func canonicalizeQuantities(amounts map[string]interface{}) {
+	for name, v := range amounts {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case int64, float64:
+			s, _ = numberString(v)
+		default:
+			continue
+		}
+		q, err := resource.ParseQuantity(s)
+		if err != nil {
+			continue
+		}
+		d := q.AsDec().String()
+		if strings.Contains(d, ".") {
+			d = strings.TrimRight(strings.TrimRight(d, "0"), ".")
+		}
+		amounts[name] = d
+	}
+}
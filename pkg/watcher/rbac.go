@@ -0,0 +1,123 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const rbacGroup = "rbac.authorization.k8s.io"
+
+func summarizeRole(old, new *unstructured.Unstructured) string {
+	return summarizeSets(old, new, "rule", rules)
+}
+
+// summarizeBinding reports the role a binding grants and the subjects added
+// to and removed from it. roleRef is immutable, so it only changes when the
+// binding is recreated.
+func summarizeBinding(old, new *unstructured.Unstructured) string {
+	return summarizeSets(old, new, "subject", subjects)
+}
+
+// summarizeSets describes an object by the elements items extracts from it:
+// all of them when it is created or deleted, else the ones that changed.
+func summarizeSets(old, new *unstructured.Unstructured, noun string, items func(*unstructured.Unstructured) sets.Set[string]) string {
+	var lines []string
+	switch {
+	case len(old.Object) == 0:
+		lines = append(lines, "created"+roleRef(new))
+	case len(new.Object) == 0:
+		lines = append(lines, "deleted"+roleRef(old))
+	}
+	before, after := items(old), items(new)
+	for _, s := range sets.List(after.Difference(before)) {
+		lines = append(lines, "+ "+noun+" "+s)
+	}
+	for _, s := range sets.List(before.Difference(after)) {
+		lines = append(lines, "- "+noun+" "+s)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func roleRef(binding *unstructured.Unstructured) string {
+	kind, _, _ := unstructured.NestedString(binding.Object, "roleRef", "kind")
+	name, _, _ := unstructured.NestedString(binding.Object, "roleRef", "name")
+	if kind == "" {
+		return ""
+	}
+	return ", bound to " + kind + "/" + name
+}
+
+// rules describes every policy rule of a role, e.g.
+// "get,list on deployments.apps (names: web)".
+func rules(role *unstructured.Unstructured) sets.Set[string] {
+	list, _, _ := unstructured.NestedSlice(role.Object, "rules")
+	set := sets.New[string]()
+	for _, r := range list {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		verbs, _, _ := unstructured.NestedStringSlice(m, "verbs")
+		groups, _, _ := unstructured.NestedStringSlice(m, "apiGroups")
+		resources, _, _ := unstructured.NestedStringSlice(m, "resources")
+		names, _, _ := unstructured.NestedStringSlice(m, "resourceNames")
+		urls, _, _ := unstructured.NestedStringSlice(m, "nonResourceURLs")
+
+		var targets []string
+		for _, res := range resources {
+			for _, g := range groups {
+				if g == "" {
+					targets = append(targets, res)
+				} else {
+					targets = append(targets, res+"."+g)
+				}
+			}
+		}
+		targets = append(targets, urls...)
+		sort.Strings(targets)
+		s := strings.Join(verbs, ",") + " on " + strings.Join(targets, ",")
+		if len(names) != 0 {
+			s += " (names: " + strings.Join(names, ",") + ")"
+		}
+		set.Insert(s)
+	}
+	return set
+}
+
+// subjects describes the subjects of a binding, e.g. "ServiceAccount ns/name".
+func subjects(binding *unstructured.Unstructured) sets.Set[string] {
+	list, _, _ := unstructured.NestedSlice(binding.Object, "subjects")
+	set := sets.New[string]()
+	for _, s := range list {
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(m, "kind")
+		name, _, _ := unstructured.NestedString(m, "name")
+		if ns, _, _ := unstructured.NestedString(m, "namespace"); ns != "" {
+			name = ns + "/" + name
+		}
+		set.Insert(kind + " " + name)
+	}
+	return set
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const redacted = "<redacted>"
+
+var (
+	redactPaths   = pflag.StringSlice("redact-path", nil, "Coma separated list of dotted field paths whose values are redacted before diffing, e.g. data.token (* matches any key)")
+	redactRegexes = pflag.StringArray("redact-regex", nil, "Redact any string value matching the regular expression (may be repeated)")
+
+	redactPathSegments [][]string
+	redactPatterns     []*regexp.Regexp
+)
+
+func compileRedactions() error {
+	for _, p := range *redactPaths {
+		redactPathSegments = append(redactPathSegments, strings.Split(p, "."))
+	}
+	for _, expr := range *redactRegexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		redactPatterns = append(redactPatterns, re)
+	}
+	return nil
+}
+
+// redact masks the configured paths and patterns in obj in place.
+func redact(obj map[string]interface{}) {
+	for _, segments := range redactPathSegments {
+		redactPath(obj, segments)
+	}
+	if len(redactPatterns) != 0 {
+		redactValues(obj)
+	}
+}
+
+func redactPath(v interface{}, segments []string) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			redactPath(item, segments)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if segments[0] != "*" && segments[0] != key {
+				continue
+			}
+			if len(segments) == 1 {
+				v[key] = redacted
+			} else {
+				redactPath(value, segments[1:])
+			}
+		}
+	}
+}
+
+func redactValues(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValues(item)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = redactValues(value)
+		}
+	case string:
+		for _, re := range redactPatterns {
+			if re.MatchString(v) {
+				return redacted
+			}
+		}
+	}
+	return v
+}
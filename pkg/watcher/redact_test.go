@@ -0,0 +1,86 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func secretPod(image, password string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":      "pod",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"team": "payroll", "owner": "hunter-ops"},
+		},
+		"spec": map[string]interface{}{
+			"nodeName": "node-7",
+			"containers": []interface{}{map[string]interface{}{
+				"name":  "app",
+				"image": image,
+				"env":   []interface{}{map[string]interface{}{"name": "PASSWORD", "value": password}},
+			}},
+		},
+	}}
+}
+
+func TestRedact(t *testing.T) {
+	defer func(p []string, r []string) { *redactPaths, *redactRegexes = p, r }(*redactPaths, *redactRegexes)
+	defer func() { redactPathSegments, redactPatterns = nil, nil }()
+	*redactPaths = []string{"metadata.labels.team", "spec.nodeName"}
+	*redactRegexes = []string{"^hunter"}
+	if err := compileRedactions(); err != nil {
+		t.Fatal(err)
+	}
+	namespaceFilter = NewFilter(nil)
+
+	cache := newObjectCache(nil, schema.GroupVersionResource{Version: "v1", Resource: "pods"})
+	var events []*Event
+	for _, event := range []watch.Event{
+		{Type: watch.Added, Object: secretPod("app:1", "hunter2")},
+		{Type: watch.Modified, Object: secretPod("app:2", "hunter3")},
+		{Type: watch.Deleted, Object: secretPod("app:2", "hunter3")},
+	} {
+		if e := processEvent(event, cache); e != nil {
+			events = append(events, e)
+		}
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	for _, f := range []EventFormatter{&DefaultFormatter{}, &TraceEventFormatter{}} {
+		for i, e := range events {
+			out := f.Format(e)
+			if !strings.Contains(out, redacted) && !strings.Contains(out, "\\u003credacted\\u003e") {
+				t.Errorf("%T: expected redacted values in %q", f, out)
+			}
+			for _, secret := range []string{"payroll", "hunter", "node-7"} {
+				if strings.Contains(out, secret) {
+					t.Errorf("%T: event %d shows %q: %q", f, i, secret, out)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+var discoveryRefresh = pflag.Duration("discovery-refresh", 0, "Rediscover the resources of every cluster at this interval and restart the watches of those whose preferred version changed, e.g. with a CRD upgrade (0 disables)")
+
+// A watchSet tracks the version every resource of a cluster is watched at,
+// so that --discovery-refresh can restart the watches of resources whose
+// preferred version changed.
+type watchSet struct {
+	mu      sync.Mutex
+	watches map[schema.GroupResource]*runningWatch
+}
+
+type runningWatch struct {
+	version string
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func (w *runningWatch) close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func newWatchSet() *watchSet {
+	return &watchSet{watches: map[schema.GroupResource]*runningWatch{}}
+}
+
+// start records the watch of gvr and returns the channel stopping it, closed
+// along with stopCh or when the watch is restarted. Without a set, it is
+// stopCh itself.
+func (s *watchSet) start(gvr schema.GroupVersionResource, stopCh <-chan struct{}) <-chan struct{} {
+	if s == nil {
+		return stopCh
+	}
+	w := &runningWatch{version: gvr.Version, stop: make(chan struct{})}
+	s.mu.Lock()
+	s.watches[gvr.GroupResource()] = w
+	s.mu.Unlock()
+	go func() {
+		select {
+		case <-stopCh:
+			w.close()
+		case <-w.stop:
+		}
+	}()
+	return w.stop
+}
+
+// outdated stops and returns the watches of the resources whose version in
+// preferred differs from the watched one, with the version to watch them at.
+func (s *watchSet) outdated(preferred map[schema.GroupResource]string) []schema.GroupVersionResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var restart []schema.GroupVersionResource
+	for gr, w := range s.watches {
+		v, ok := preferred[gr]
+		if !ok || v == w.version {
+			continue
+		}
+		klog.Infof("preferred version of '%v' changed from %s to %s, restarting its watch", gr, w.version, v)
+		w.close()
+		delete(s.watches, gr)
+		restart = append(restart, gr.WithVersion(v))
+	}
+	return restart
+}
+
+// refreshDiscovery rediscovers the resources of cl every interval until
+// stopCh is closed, and restarts the watches of those whose preferred
+// version changed against a fresh cache. Changes made while the new cache
+// is listed are not reported.
+func refreshDiscovery(cl *Cluster, interval time.Duration, out chan<- *Event, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		if cached, ok := cl.disc.(discovery.CachedDiscoveryInterface); ok {
+			cached.Invalidate()
+		}
+		resources, err := cl.disc.ServerPreferredResources()
+		if _, ok := err.(*discovery.ErrGroupDiscoveryFailed); err != nil && !ok {
+			klog.V(2).Infof("error rediscovering resources of cluster %s: %v", cl.name, err)
+			continue
+		}
+		preferred := map[schema.GroupResource]string{}
+		for _, g := range resources {
+			gv, err := schema.ParseGroupVersion(g.GroupVersion)
+			if err != nil {
+				continue
+			}
+			for _, r := range g.APIResources {
+				preferred[schema.GroupResource{Group: gv.Group, Resource: r.Name}] = gv.Version
+			}
+		}
+		for _, gvr := range cl.watches.outdated(preferred) {
+			goWorker(func() {
+				cache, _ := cacheResource(cl, gvr, stopCh)
+				watchResource(cl, gvr, out, cache, cl.watches.start(gvr, stopCh))
+			})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var reportFile = pflag.String("report-file", "", "On shutdown, write a JSON report of the session to this file: the events printed by type and resource, the objects and field paths that changed most often (as many as --top, else 10), the run duration and the reconnects of every watcher")
+
+type sessionReport struct {
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Duration   string          `json:"duration"`
+	ExitCode   int             `json:"exitCode"`
+	Events     int             `json:"events"`
+	Types      map[string]int  `json:"types"`
+	Resources  map[string]int  `json:"resources"`
+	TopObjects []reportCount   `json:"topObjects"`
+	TopPaths   []reportCount   `json:"topPaths"`
+	Reconnects int             `json:"reconnects"`
+	Watchers   []watcherHealth `json:"watchers"`
+}
+
+type reportCount struct {
+	Name    string `json:"name"`
+	Changes int    `json:"changes"`
+}
+
+func topCounts(counts map[string]int, n int) []reportCount {
+	top := []reportCount{}
+	for _, k := range topKeys(counts, n) {
+		top = append(top, reportCount{k, counts[k]})
+	}
+	return top
+}
+
+// writeReport writes the --report-file of a run that exited with code.
+func writeReport(path string, c *changeCounter, code int) error {
+	n := *topN
+	if n <= 0 {
+		n = 10
+	}
+	end := Now()
+	r := sessionReport{
+		Start:      startTime,
+		End:        end,
+		Duration:   end.Sub(startTime).Round(time.Second).String(),
+		ExitCode:   code,
+		Events:     c.events,
+		Types:      c.types,
+		Resources:  c.resources,
+		TopObjects: topCounts(c.objects, n),
+		TopPaths:   topCounts(c.paths, n),
+		Watchers:   watchHealth.snapshot(),
+	}
+	for _, w := range r.Watchers {
+		r.Reconnects += w.Reconnects
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
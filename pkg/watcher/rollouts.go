@@ -0,0 +1,139 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const rolloutRevisionAnnotation = "rollout.argoproj.io/revision"
+
+// summarizeRollout reports the progress of an Argo Rollout as a timeline,
+// e.g. "step 2/5, weight 40%, Progressing" for canaries, along with new
+// revisions, the ReplicaSets blue-green ones switch between, pauses and
+// aborts.
+func summarizeRollout(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: %s, %s", rolloutStrategy(new), rolloutProgress(new))
+	case len(new.Object) == 0:
+		return "deleted"
+	}
+	var lines []string
+	if before, after := old.GetAnnotations()[rolloutRevisionAnnotation], new.GetAnnotations()[rolloutRevisionAnnotation]; before != after {
+		lines = append(lines, fmt.Sprintf("revision %s -> %s", orNone(before), orNone(after)))
+	}
+	if progress := rolloutProgress(new); progress != rolloutProgress(old) {
+		if msg, _, _ := unstructured.NestedString(new.Object, "status", "message"); msg != "" {
+			progress += ": " + msg
+		}
+		lines = append(lines, progress)
+	}
+	for _, field := range []string{"activeSelector", "previewSelector"} {
+		before, _, _ := unstructured.NestedString(old.Object, "status", "blueGreen", field)
+		after, _, _ := unstructured.NestedString(new.Object, "status", "blueGreen", field)
+		if before != after {
+			lines = append(lines, fmt.Sprintf("%s %s -> %s", strings.TrimSuffix(field, "Selector"), orNone(before), orNone(after)))
+		}
+	}
+	if before, after := rolloutPauses(old), rolloutPauses(new); before != after {
+		if after == "" {
+			lines = append(lines, "resumed")
+		} else {
+			lines = append(lines, "paused: "+after)
+		}
+	}
+	before, _, _ := unstructured.NestedBool(old.Object, "status", "abort")
+	after, _, _ := unstructured.NestedBool(new.Object, "status", "abort")
+	if before != after {
+		if after {
+			lines = append(lines, "aborted")
+		} else {
+			lines = append(lines, "retried")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rolloutStrategy(o *unstructured.Unstructured) string {
+	if _, ok, _ := unstructured.NestedMap(o.Object, "spec", "strategy", "blueGreen"); ok {
+		return "blue-green"
+	}
+	return "canary"
+}
+
+// rolloutProgress returns the step and weight of a canary, if it has steps,
+// and the phase of a Rollout, e.g. "step 2/5, weight 40%, Progressing".
+func rolloutProgress(o *unstructured.Unstructured) string {
+	var parts []string
+	steps, _, _ := unstructured.NestedSlice(o.Object, "spec", "strategy", "canary", "steps")
+	if len(steps) != 0 {
+		// currentStepIndex is the step being executed, and the number of
+		// steps once they have all completed.
+		index, ok, _ := unstructured.NestedInt64(o.Object, "status", "currentStepIndex")
+		switch {
+		case !ok:
+		case int(index) >= len(steps):
+			parts = append(parts, fmt.Sprintf("%d/%d steps done", len(steps), len(steps)))
+		default:
+			parts = append(parts, fmt.Sprintf("step %d/%d", index+1, len(steps)))
+		}
+		if weight, ok := canaryWeight(o, steps, index); ok {
+			parts = append(parts, fmt.Sprintf("weight %d%%", weight))
+		}
+	}
+	phase, _, _ := unstructured.NestedString(o.Object, "status", "phase")
+	return strings.Join(append(parts, orNone(phase)), ", ")
+}
+
+// canaryWeight returns the traffic weight of a canary, as reported by the
+// traffic router or else as last set by its steps before index.
+func canaryWeight(o *unstructured.Unstructured, steps []interface{}, index int64) (int64, bool) {
+	if w, ok, _ := unstructured.NestedInt64(o.Object, "status", "canary", "weights", "canary", "weight"); ok {
+		return w, true
+	}
+	var weight int64
+	found := false
+	for i := 0; i < len(steps) && int64(i) < index; i++ {
+		if step, ok := steps[i].(map[string]interface{}); ok {
+			if w, ok, _ := unstructured.NestedInt64(step, "setWeight"); ok {
+				weight, found = w, true
+			}
+		}
+	}
+	return weight, found
+}
+
+// rolloutPauses returns the reasons a Rollout is paused for, if any.
+func rolloutPauses(o *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(o.Object, "status", "pauseConditions")
+	var reasons []string
+	for _, c := range conditions {
+		if m, ok := c.(map[string]interface{}); ok {
+			if reason, _, _ := unstructured.NestedString(m, "reason"); reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	if paused, _, _ := unstructured.NestedBool(o.Object, "spec", "paused"); paused && len(reasons) == 0 {
+		reasons = append(reasons, "spec.paused")
+	}
+	return strings.Join(reasons, ",")
+}
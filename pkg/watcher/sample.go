@@ -0,0 +1,147 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+var (
+	sampleFlag = pflag.String("sample", "", "Print only every Nth update, given as 1/N, of each resource or object (see --sample-by). Additions and deletions are always printed")
+	sampleBy   = pflag.String("sample-by", "resource", "What --sample counts updates of: resource or object")
+
+	chattyThreshold = pflag.Float64("chatty-threshold", 0, "Sample the updates of any resource getting more than this many events per second over --chatty-window, at --chatty-sample, until its rate drops below half of it (0 disables)")
+	chattyWindow    = pflag.Duration("chatty-window", 10*time.Second, "Sliding window over which --chatty-threshold measures the event rate of every resource")
+	chattySample    = pflag.Int("chatty-sample", 10, "Print only every Nth update of resources over --chatty-threshold (see --sample-by)")
+
+	sampleRate int
+)
+
+func parseSample() error {
+	switch *sampleBy {
+	case "resource", "object":
+	default:
+		return fmt.Errorf("unknown --sample-by %q", *sampleBy)
+	}
+	if *sampleFlag == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(*sampleFlag, "1/"))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid --sample %q, expected 1/N", *sampleFlag)
+	}
+	sampleRate = n
+	return nil
+}
+
+func validateChatty() error {
+	if *chattyThreshold > 0 && (*chattySample < 1 || *chattyWindow <= 0) {
+		return fmt.Errorf("--chatty-sample and --chatty-window must be positive")
+	}
+	return nil
+}
+
+// A sampler thins out the updates of one resource. With --chatty-threshold,
+// it also tracks the times of the events of the resource within the window,
+// and while their rate is over the threshold samples at --chatty-sample. It
+// is used by a single goroutine.
+type sampler struct {
+	skipped map[string]int
+	// resource names the resource in notices.
+	resource string
+	times    []time.Time
+	chatty   bool
+}
+
+// newSampler returns nil when sampling is disabled.
+func newSampler(cl *Cluster, gvr schema.GroupVersionResource) *sampler {
+	if sampleRate <= 1 && *chattyThreshold <= 0 {
+		return nil
+	}
+	resource := gvr.GroupVersion().String() + "/" + gvr.Resource
+	if cl.label != "" {
+		resource = "[" + cl.label + "] " + resource
+	}
+	return &sampler{skipped: map[string]int{}, resource: resource}
+}
+
+// rate returns how many updates in a row are printed one of.
+func (s *sampler) rate() int {
+	if *chattyThreshold <= 0 {
+		return sampleRate
+	}
+	now := Now()
+	s.times = append(s.times, now)
+	i := 0
+	for i < len(s.times) && now.Sub(s.times[i]) > *chattyWindow {
+		i++
+	}
+	s.times = s.times[i:]
+	perSecond := float64(len(s.times)) / chattyWindow.Seconds()
+	switch {
+	case !s.chatty && perSecond > *chattyThreshold:
+		s.chatty = true
+		klog.Warningf("%s is getting %.1f events/s, printing only 1 in %d of its updates until it calms down", s.resource, perSecond, *chattySample)
+	case s.chatty && perSecond < *chattyThreshold/2:
+		s.chatty = false
+		klog.Infof("%s calmed down to %.1f events/s, printing all its updates again", s.resource, perSecond)
+	}
+	if s.chatty {
+		return max(sampleRate, *chattySample)
+	}
+	return sampleRate
+}
+
+// keep reports whether e should be printed and if so records in it how many
+// updates were skipped before it.
+func (s *sampler) keep(e *Event) bool {
+	if s == nil {
+		return true
+	}
+	rate := s.rate()
+	key := ""
+	if *sampleBy == "object" {
+		key = e.Name
+	}
+	switch e.Type {
+	case watch.Modified:
+	case watch.Deleted:
+		if key != "" {
+			e.Skipped = s.skipped[key]
+			delete(s.skipped, key)
+		}
+		return true
+	default:
+		return true
+	}
+	if n := s.skipped[key]; n+1 < rate {
+		s.skipped[key] = n + 1
+		return false
+	}
+	e.Skipped = s.skipped[key]
+	s.skipped[key] = 0
+	return true
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yudai/gojsondiff/formatter"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxAlignCells bounds the time of the quadratic line alignment. Larger
+// differences are paired up line by line.
+const maxAlignCells = 1 << 20
+
+// SideBySideFormatter prints the old and new JSON of an object in two columns,
+// marking changed lines in the gutter between them like sdiff.
+type SideBySideFormatter struct {
+	DefaultFormatter
+	Color bool
+}
+
+func (f *SideBySideFormatter) Format(event *Event) string {
+	// Summaries of focus modes are printed as they are.
+	if event.Old == nil || summarizers[event.Object.GroupVersionKind().GroupKind()] != nil {
+		return f.DefaultFormatter.Format(event)
+	}
+	if f.Color && f.plain(event) {
+		plain := *f
+		plain.Color = false
+		return plain.Format(event)
+	}
+	new := event.Object
+	if event.Type == watch.Deleted {
+		new = emptyUnstructured
+	}
+	rows := alignLines(objectLines(event.Old), objectLines(new))
+
+	leftWidth, rightWidth := 1, 1
+	if f.MaxWidth > 0 {
+		leftWidth = max((f.MaxWidth-3)/2, 1)
+		rightWidth = leftWidth
+	} else {
+		for _, r := range rows {
+			leftWidth = max(leftWidth, utf8.RuneCountInString(r.left))
+			rightWidth = max(rightWidth, utf8.RuneCountInString(r.right))
+		}
+	}
+
+	var buf strings.Builder
+	buf.WriteString(f.header(event))
+	for _, r := range rows {
+		line := f.cell(r.left, leftWidth, true, r.op == opChange || r.op == opDelete, formatter.AsciiDeleted) +
+			gutters[r.op] +
+			f.cell(r.right, rightWidth, false, r.op == opChange || r.op == opInsert, formatter.AsciiAdded)
+		buf.WriteString(strings.TrimRight(line, " "))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// cell sanitizes and truncates s to width runes, optionally padding it to
+// exactly width, and highlights it if changed.
+func (f *SideBySideFormatter) cell(s string, width int, pad, changed bool, marker string) string {
+	s = sanitize(s)
+	if n := utf8.RuneCountInString(s); n > width {
+		s = string([]rune(s)[:width-1]) + ellipsis
+	} else if pad {
+		s += strings.Repeat(" ", width-n)
+	}
+	if style := formatter.AsciiStyles[marker]; f.Color && changed && style != "" {
+		s = "\x1b[" + style + "m" + s + "\x1b[0m"
+	}
+	return s
+}
+
+func objectLines(o *unstructured.Unstructured) []string {
+	if len(o.Object) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(o.Object, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+type lineOp int
+
+const (
+	opEqual lineOp = iota
+	opChange
+	opDelete
+	opInsert
+)
+
+var gutters = map[lineOp]string{
+	opEqual:  "   ",
+	opChange: " | ",
+	opDelete: " < ",
+	opInsert: " > ",
+}
+
+type lineRow struct {
+	op          lineOp
+	left, right string
+}
+
+// alignLines pairs up the lines of a and b along their longest common
+// subsequence. Runs of removed and added lines in between are shown side by
+// side as changes.
+func alignLines(a, b []string) []lineRow {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var rows []lineRow
+	for _, l := range a[:prefix] {
+		rows = append(rows, lineRow{op: opEqual, left: l, right: l})
+	}
+	rows = appendMiddle(rows, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, l := range a[len(a)-suffix:] {
+		rows = append(rows, lineRow{op: opEqual, left: l, right: l})
+	}
+	return rows
+}
+
+func appendMiddle(rows []lineRow, a, b []string) []lineRow {
+	if len(a)*len(b) > maxAlignCells {
+		return appendChanged(rows, a, b)
+	}
+	i, j := 0, 0
+	for _, m := range lcsMatches(a, b, 0, 0, nil) {
+		rows = appendChanged(rows, a[i:m[0]], b[j:m[1]])
+		rows = append(rows, lineRow{op: opEqual, left: a[m[0]], right: b[m[1]]})
+		i, j = m[0]+1, m[1]+1
+	}
+	return appendChanged(rows, a[i:], b[j:])
+}
+
+// lcsMatches appends to matches the indexes, offset by ai and bi, of the
+// lines of a and b paired up along a longest common subsequence. It splits a
+// in halves and b where their subsequences meet, as Hirschberg's algorithm
+// does, which takes space linear in the number of lines.
+func lcsMatches(a, b []string, ai, bi int, matches [][2]int) [][2]int {
+	if len(a) == 0 || len(b) == 0 {
+		return matches
+	}
+	if len(a) == 1 {
+		for j, l := range b {
+			if l == a[0] {
+				return append(matches, [2]int{ai, bi + j})
+			}
+		}
+		return matches
+	}
+	mid := len(a) / 2
+	head, tail := lcsPrefixLengths(a[:mid], b), lcsSuffixLengths(a[mid:], b)
+	split := 0
+	for k := range head {
+		if head[k]+tail[k] > head[split]+tail[split] {
+			split = k
+		}
+	}
+	matches = lcsMatches(a[:mid], b[:split], ai, bi, matches)
+	return lcsMatches(a[mid:], b[split:], ai+mid, bi+split, matches)
+}
+
+// lcsPrefixLengths returns the lengths of the longest common subsequences of
+// a and every prefix b[:j].
+func lcsPrefixLengths(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	for _, l := range a {
+		diag := 0
+		for j := 1; j <= len(b); j++ {
+			up := row[j]
+			if l == b[j-1] {
+				row[j] = diag + 1
+			} else {
+				row[j] = max(row[j], row[j-1])
+			}
+			diag = up
+		}
+	}
+	return row
+}
+
+// lcsSuffixLengths returns the lengths of the longest common subsequences of
+// a and every suffix b[j:].
+func lcsSuffixLengths(a, b []string) []int {
+	row := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		diag := 0
+		for j := len(b) - 1; j >= 0; j-- {
+			up := row[j]
+			if a[i] == b[j] {
+				row[j] = diag + 1
+			} else {
+				row[j] = max(row[j], row[j+1])
+			}
+			diag = up
+		}
+	}
+	return row
+}
+
+func appendChanged(rows []lineRow, a, b []string) []lineRow {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i >= len(a):
+			rows = append(rows, lineRow{op: opInsert, right: b[i]})
+		case i >= len(b):
+			rows = append(rows, lineRow{op: opDelete, left: a[i]})
+		default:
+			rows = append(rows, lineRow{op: opChange, left: a[i], right: b[i]})
+		}
+	}
+	return rows
+}
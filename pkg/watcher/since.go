@@ -0,0 +1,94 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var (
+	sinceLastRestart = pflag.String("since-last-restart", "", "Show the objects last modified after a reference time as added during the initial sync: the last start of a container of this [namespace/]pod, or an RFC3339 time")
+
+	sinceTime time.Time
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// resolveSince sets the reference time of --since-last-restart, looking up
+// the pod it names in the clusters in order.
+func resolveSince(clusters []*Cluster) error {
+	if *sinceLastRestart == "" {
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, *sinceLastRestart); err == nil {
+		sinceTime = t
+		return nil
+	}
+	ns, name, ok := strings.Cut(*sinceLastRestart, "/")
+	if !ok {
+		ns, name = metav1.NamespaceDefault, ns
+	}
+	for _, cl := range clusters {
+		pod, err := cl.dc.Resource(podsResource).Namespace(ns).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			klog.V(2).Infof("error getting pod %s/%s of cluster %s: %v", ns, name, cl.name, err)
+			continue
+		}
+		sinceTime = podStarted(pod)
+		if sinceTime.IsZero() {
+			return fmt.Errorf("pod %s/%s has not started", ns, name)
+		}
+		klog.V(2).Infof("showing objects modified since %v", sinceTime)
+		return nil
+	}
+	return fmt.Errorf("--since-last-restart is neither an RFC3339 time nor an existing pod: %s", *sinceLastRestart)
+}
+
+// podStarted returns when the most recently (re)started container of pod
+// started, or when the pod did if none is running.
+func podStarted(pod *unstructured.Unstructured) time.Time {
+	var started time.Time
+	statuses, _, _ := unstructured.NestedSlice(pod.Object, "status", "containerStatuses")
+	for _, s := range statuses {
+		s, _ := s.(map[string]interface{})
+		at, _, _ := unstructured.NestedString(s, "state", "running", "startedAt")
+		if t, err := time.Parse(time.RFC3339, at); err == nil && t.After(started) {
+			started = t
+		}
+	}
+	if started.IsZero() {
+		at, _, _ := unstructured.NestedString(pod.Object, "status", "startTime")
+		started, _ = time.Parse(time.RFC3339, at)
+	}
+	return started
+}
+
+// modifiedSince reports whether the listed object o was last modified after
+// the --since-last-restart reference time.
+func modifiedSince(o *unstructured.Unstructured) bool {
+	return !sinceTime.IsZero() && !lastModified(o).Before(sinceTime)
+}
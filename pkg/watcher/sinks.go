@@ -0,0 +1,172 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var (
+	tees          = pflag.StringArray("tee", nil, "Also write events to another destination in its own format, given as format=path, e.g. ndjson=capture.ndjson (- is stdout, .gz paths are compressed; may be repeated)")
+	webhookURL    = pflag.String("webhook-url", "", "Also POST every event to this URL, formatted by --webhook-format")
+	webhookFormat = pflag.String("webhook-format", "ndjson", "Output format of the events posted to --webhook-url")
+)
+
+// A Sink is a destination of events, written in the format of its formatter.
+type Sink struct {
+	Writer    io.Writer
+	Formatter EventFormatter
+}
+
+type sinks []Sink
+
+func (s sinks) write(format func(EventFormatter) string) {
+	for _, sink := range s {
+		fmt.Fprint(sink.Writer, format(sink.Formatter))
+	}
+}
+
+func (s sinks) preamble() {
+	s.write(EventFormatter.Preamble)
+}
+
+func (s sinks) epilogue() {
+	s.write(EventFormatter.Epilogue)
+}
+
+func (s sinks) marker(ts time.Time, text string) {
+	s.write(func(f EventFormatter) string { return f.Marker(ts, text) })
+}
+
+// event writes e to every sink. With --status-to-stderr, status only changes
+// go to stderr instead of the first sink.
+func (s sinks) event(e *Event) {
+	for i, sink := range s {
+		w := sink.Writer
+		if i == 0 && *statusToStderr && isStatusOnly(e.Paths) {
+			w = os.Stderr
+		}
+		fmt.Fprint(w, sink.Formatter.Format(e))
+	}
+}
+
+// openSinks opens the destinations of --tee and --webhook-url. The returned
+// closers must be closed once the sinks are done with.
+func openSinks() ([]Sink, []io.Closer, error) {
+	var list []Sink
+	var closers []io.Closer
+	fail := func(err error) ([]Sink, []io.Closer, error) {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, nil, err
+	}
+	for _, tee := range *tees {
+		format, path, ok := strings.Cut(tee, "=")
+		if !ok || path == "" {
+			return fail(fmt.Errorf("invalid --tee %q, expected format=path", tee))
+		}
+		var w io.Writer = os.Stdout
+		if path != "-" {
+			f, err := openOutputFile(path)
+			if err != nil {
+				return fail(fmt.Errorf("error opening --tee file: %v", err))
+			}
+			closers = append(closers, f)
+			w = f
+		}
+		f, err := formatterFor(format, *maxWidth, path == "-" && *colorize)
+		if err != nil {
+			return fail(err)
+		}
+		list = append(list, Sink{Writer: w, Formatter: f})
+	}
+	if *webhookURL != "" {
+		f, err := formatterFor(*webhookFormat, 0, false)
+		if err != nil {
+			return fail(err)
+		}
+		w := newWebhookWriter(*webhookURL)
+		closers = append(closers, w)
+		list = append(list, Sink{Writer: w, Formatter: f})
+	}
+	return list, closers, nil
+}
+
+// A webhookWriter posts every write, which is one formatted event, to a URL
+// from a goroutine of its own. Writes are dropped while it falls behind.
+type webhookWriter struct {
+	url    string
+	client *http.Client
+	bodies chan []byte
+	done   sync.WaitGroup
+}
+
+func newWebhookWriter(url string) *webhookWriter {
+	w := &webhookWriter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		bodies: make(chan []byte, 1000),
+	}
+	w.done.Add(1)
+	go w.run()
+	return w
+}
+
+func (w *webhookWriter) Write(p []byte) (int, error) {
+	if len(bytes.TrimSpace(p)) == 0 {
+		return len(p), nil
+	}
+	select {
+	case w.bodies <- bytes.Clone(p):
+	default:
+		klog.Warning("dropping event, webhook is falling behind")
+	}
+	return len(p), nil
+}
+
+// Close waits for the queued events to be posted.
+func (w *webhookWriter) Close() error {
+	close(w.bodies)
+	w.done.Wait()
+	return nil
+}
+
+func (w *webhookWriter) run() {
+	defer w.done.Done()
+	for body := range w.bodies {
+		resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			klog.Error("error posting event: ", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			klog.Errorf("error posting event: %s", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var exitOnStdinClose = pflag.Bool("exit-on-stdin-close", false, "Stop gracefully, flushing pending events, once stdin is closed, so that a wrapper can end the run by closing the pipe")
+
+func validateExitOnStdinClose() error {
+	if !*exitOnStdinClose {
+		return nil
+	}
+	if *interactive {
+		return fmt.Errorf("--exit-on-stdin-close cannot be combined with --interactive, which reads key presses from stdin")
+	}
+	for _, k := range *kubeconfigs {
+		if k == stdinKubeconfig {
+			return fmt.Errorf("--exit-on-stdin-close cannot be combined with --kubeconfig %s, which reads stdin to its end", stdinKubeconfig)
+		}
+	}
+	return nil
+}
+
+var (
+	stdinOnce   sync.Once
+	stdinClosed = make(chan struct{})
+)
+
+// discardStdin discards stdin until it is closed, once per process since a
+// closed stdin stays closed across runs, and returns a channel closed then.
+func discardStdin() <-chan struct{} {
+	stdinOnce.Do(func() {
+		go func() {
+			if _, err := io.Copy(io.Discard, os.Stdin); err != nil {
+				klog.Warning("error reading stdin: ", err)
+			}
+			close(stdinClosed)
+		}()
+	})
+	return stdinClosed
+}
+
+// stopOnStdinClose stops once stdin is closed, unless stopCh is closed first,
+// so that it never stops a later run.
+func stopOnStdinClose(stopCh <-chan struct{}) {
+	select {
+	case <-discardStdin():
+		klog.V(2).Info("stdin closed, stopping")
+		stop(0)
+	case <-stopCh:
+	}
+}
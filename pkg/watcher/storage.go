@@ -0,0 +1,70 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// summarizeStorageBinding returns a summarizer reporting the phase of a
+// claim or volume and the object it is bound to as returned by ref.
+func summarizeStorageBinding(noun string, ref func(*unstructured.Unstructured) string) summarizer {
+	return func(old, new *unstructured.Unstructured) string {
+		switch {
+		case len(old.Object) == 0:
+			return fmt.Sprintf("created: phase %s, %s %s", storagePhase(new), noun, ref(new))
+		case len(new.Object) == 0:
+			return fmt.Sprintf("deleted: phase %s, %s %s", storagePhase(old), noun, ref(old))
+		}
+		var changes []string
+		if before, after := storagePhase(old), storagePhase(new); before != after {
+			changes = append(changes, fmt.Sprintf("phase %s -> %s", before, after))
+		}
+		if before, after := ref(old), ref(new); before != after {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", noun, before, after))
+		}
+		return strings.Join(changes, ", ")
+	}
+}
+
+func storagePhase(o *unstructured.Unstructured) string {
+	if p, _, _ := unstructured.NestedString(o.Object, "status", "phase"); p != "" {
+		return p
+	}
+	return "<none>"
+}
+
+// claimVolume returns the name of the volume bound to a claim.
+func claimVolume(pvc *unstructured.Unstructured) string {
+	if name, _, _ := unstructured.NestedString(pvc.Object, "spec", "volumeName"); name != "" {
+		return name
+	}
+	return "<none>"
+}
+
+// volumeClaim returns the namespace/name of the claim bound to a volume.
+func volumeClaim(pv *unstructured.Unstructured) string {
+	name, _, _ := unstructured.NestedString(pv.Object, "spec", "claimRef", "name")
+	if name == "" {
+		return "<none>"
+	}
+	ns, _, _ := unstructured.NestedString(pv.Object, "spec", "claimRef", "namespace")
+	return ns + "/" + name
+}
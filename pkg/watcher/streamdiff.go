@@ -0,0 +1,212 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff/formatter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var streamDiffThreshold = pflag.Int64("stream-diff-threshold", 0, "Diff updates of objects whose approximate in-memory size exceeds this many bytes line by line over their YAML, as -o kubectl-diff does. This takes far less memory than the structural diff, but the changed fields are not known, so notes based on them are left out, and the structural diff is still used with --human-changes-only, --exclude-managed-by, --server-side-apply-only and --labels-annotations-only (0 disables)")
+
+// streamsDiff reports whether updates to o are diffed line by line. They
+// never are with the filters that need the changed fields, which a line diff
+// does not tell.
+func streamsDiff(o *unstructured.Unstructured) bool {
+	if *humanChangesOnly || len(*excludeManagedBy) != 0 || *serverSideApplyOnly || *labelsAnnotationsOnly {
+		return false
+	}
+	return *streamDiffThreshold > 0 && approxSize(o.Object) > *streamDiffThreshold
+}
+
+// streamedDiffEvent returns the event of the update from old to new as a
+// unified diff of their YAML, or nil if only their resourceVersion or
+// managedFields changed.
+func streamedDiffEvent(now time.Time, key string, obj, old, new *unstructured.Unstructured) *Event {
+	hunks := unifiedHunks(streamYAML(old), streamYAML(new))
+	if hunks == "" {
+		return nil
+	}
+	lines := splitLines(hunks)
+	for i, l := range lines {
+		switch l[0] {
+		case '+':
+			lines[i] = colorText(gutter(l[0])+l[1:], formatter.AsciiStyles[formatter.AsciiAdded])
+		case '-':
+			lines[i] = colorText(gutter(l[0])+l[1:], formatter.AsciiStyles[formatter.AsciiDeleted])
+		case ' ':
+			lines[i] = gutter(l[0]) + l[1:]
+		}
+	}
+	return &Event{Timestamp: now, Name: key, Data: strings.Join(lines, "\n") + "\n", Type: watch.Modified, Object: obj, Old: old}
+}
+
+// streamYAML returns the lines of o as YAML without managedFields, nor its
+// resourceVersion unless --show-noop-updates is given, indented by
+// --diff-indent like structural diffs. Unlike yaml.Marshal it
+// writes them straight off the object, without intermediate encodings, and
+// prints multi-line strings as block scalars so that their lines are diffed
+// one by one.
+func streamYAML(o *unstructured.Unstructured) []string {
+	if len(o.Object) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(o.Object))
+	for k, v := range o.Object {
+		m[k] = v
+	}
+	if meta, ok := o.Object["metadata"].(map[string]interface{}); ok {
+		stripped := make(map[string]interface{}, len(meta))
+		for k, v := range meta {
+			if k != "managedFields" && (k != "resourceVersion" || *showNoopUpdates) {
+				stripped[k] = v
+			}
+		}
+		m["metadata"] = stripped
+	}
+	return appendYAMLEntries(nil, "", strings.Repeat(" ", *diffIndent), m)
+}
+
+func appendYAMLEntries(lines []string, indent, step string, m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = appendYAMLValue(lines, indent, step, yamlScalar(k)+":", m[k])
+	}
+	return lines
+}
+
+// appendYAMLValue appends the lines of v to lines, the first one starting
+// with indent and mark, a key or the dash of a list item. Nested values are
+// indented by step more, which is --diff-indent spaces.
+func appendYAMLValue(lines []string, indent, step, mark string, v interface{}) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return append(lines, indent+mark+" {}")
+		}
+		return appendYAMLEntries(append(lines, indent+mark), indent+step, step, v)
+	case []interface{}:
+		if len(v) == 0 {
+			return append(lines, indent+mark+" []")
+		}
+		lines = append(lines, indent+mark)
+		if mark == "-" {
+			// Items of nested lists have to be indented further.
+			return appendYAMLItems(lines, indent+step, step, v)
+		}
+		return appendYAMLItems(lines, indent, step, v)
+	case string:
+		if block, ok := yamlBlock(v); ok {
+			lines = append(lines, indent+mark+" "+block[0])
+			for _, l := range block[1:] {
+				if l != "" {
+					l = indent + step + l
+				}
+				lines = append(lines, l)
+			}
+			return lines
+		}
+	}
+	return append(lines, indent+mark+" "+yamlScalar(v))
+}
+
+func appendYAMLItems(lines []string, indent, step string, items []interface{}) []string {
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok && len(m) != 0 {
+			// The first entry of a map goes after the dash.
+			start := len(lines)
+			lines = appendYAMLEntries(lines, indent+step, step, m)
+			lines[start] = indent + "-" + strings.Repeat(" ", max(len(step)-1, 1)) + lines[start][len(indent)+len(step):]
+			continue
+		}
+		lines = appendYAMLValue(lines, indent, step, "-", item)
+	}
+	return lines
+}
+
+// yamlBlock returns the indicator and lines of s as a literal block scalar,
+// if it has more than one line and can be written as one.
+func yamlBlock(s string) ([]string, bool) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") || strings.HasPrefix(s, " ") || strings.HasSuffix(s, "\n\n") {
+		return nil, false
+	}
+	for _, r := range s {
+		if r != '\n' && !unicode.IsPrint(r) {
+			return nil, false
+		}
+	}
+	indicator := "|-"
+	if strings.HasSuffix(s, "\n") {
+		indicator = "|"
+	}
+	return append([]string{indicator}, splitLines(s)...), true
+}
+
+// yamlScalar returns v as a YAML scalar, quoting strings unless they are
+// plain text that doesn't read as anything else.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		if plainYAML(v) {
+			return v
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlKeywords are the words YAML 1.1 reads as booleans or null.
+var yamlKeywords = []string{"true", "false", "yes", "no", "on", "off", "y", "n", "null"}
+
+// plainYAML reports whether s can be written unquoted. Strings starting with
+// anything but a letter or a slash are quoted, which also covers numbers.
+func plainYAML(s string) bool {
+	if s == "" || !unicode.IsLetter(rune(s[0])) && s[0] != '/' ||
+		strings.HasSuffix(s, " ") || strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	for _, k := range yamlKeywords {
+		if strings.EqualFold(s, k) {
+			return false
+		}
+	}
+	return true
+}
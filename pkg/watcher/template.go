@@ -0,0 +1,136 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+var (
+	templateText = pflag.String("template", "", "Print every event with this Go template instead of the output format")
+	templateFile = pflag.String("template-file", "", "Like --template, but read the template from this file")
+)
+
+// templateEvent is what templates are executed on.
+type templateEvent struct {
+	Time    time.Time
+	Cluster string
+	Name    string
+	Type    string
+	Diff    string
+	Object  map[string]interface{}
+	Old     map[string]interface{}
+	Paths   []string
+	Skipped int
+	Seq     uint64
+	// ResourceVersion is only set with --show-resource-version.
+	ResourceVersion string
+	// Group, Version and Resource are those the object was watched as.
+	Group, Version, Resource string
+	// Node is only set with --output-annotate-node.
+	Node string
+}
+
+// TemplateFormatter prints events with a user supplied Go template.
+type TemplateFormatter struct {
+	Template *template.Template
+}
+
+// parseTemplate parses --template or --template-file. The file name is used
+// as the template name, so errors point at the file and line.
+func parseTemplate(color bool) (*template.Template, error) {
+	name, text := "template", *templateText
+	if *templateFile != "" {
+		data, err := os.ReadFile(*templateFile)
+		if err != nil {
+			return nil, err
+		}
+		name, text = *templateFile, string(data)
+	}
+	funcs := template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"color": func(name string, v interface{}) (string, error) {
+			code, ok := colorCodes[name]
+			if !ok {
+				return "", fmt.Errorf("unknown color %q", name)
+			}
+			s := fmt.Sprint(v)
+			if !color {
+				return s, nil
+			}
+			return "\x1b[" + code + "m" + s + "\x1b[0m", nil
+		},
+	}
+	return template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+}
+
+func (f *TemplateFormatter) Preamble() string {
+	return ""
+}
+
+func (f *TemplateFormatter) Epilogue() string {
+	return ""
+}
+
+func (f *TemplateFormatter) Format(event *Event) string {
+	data := templateEvent{
+		Time:            event.Timestamp,
+		Cluster:         event.Cluster,
+		Name:            event.Name,
+		Type:            string(event.Type),
+		Diff:            event.Data,
+		Skipped:         event.Skipped,
+		Seq:             event.Seq,
+		ResourceVersion: event.ResourceVersion,
+		Group:           event.Resource.Group,
+		Version:         event.Resource.Version,
+		Resource:        event.Resource.Resource,
+		Node:            event.Node,
+	}
+	if event.Object != nil {
+		data.Object = event.Object.Object
+	}
+	if event.Old != nil {
+		data.Old = event.Old.Object
+	}
+	for _, p := range event.Paths {
+		data.Paths = append(data.Paths, p.String())
+	}
+
+	var buf strings.Builder
+	if err := f.Template.Execute(&buf, data); err != nil {
+		klog.Error("error executing template: ", err)
+		return ""
+	}
+	if s := buf.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Marker prints nothing, templates fully control the output.
+func (f *TemplateFormatter) Marker(ts time.Time, text string) string {
+	return ""
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// terminationChanges describes how the termination of an object progressed
+// from old to new: when deletionTimestamp first appears it reports the grace
+// period, the finalizers holding the object and how dependents are deleted,
+// and afterwards every finalizer that is removed.
+func terminationChanges(old, new *unstructured.Unstructured) []string {
+	if new.GetDeletionTimestamp() == nil {
+		return nil
+	}
+	if old.GetDeletionTimestamp() == nil {
+		grace := "none"
+		if s := new.GetDeletionGracePeriodSeconds(); s != nil {
+			grace = fmt.Sprintf("%ds", *s)
+		}
+		return []string{fmt.Sprintf("terminating: grace period %s, %s, propagation %s",
+			grace, describeFinalizers(new.GetFinalizers()), propagation(new.GetFinalizers()))}
+	}
+
+	remaining := new.GetFinalizers()
+	kept := sets.New(remaining...)
+	var changes []string
+	for _, f := range old.GetFinalizers() {
+		if !kept.Has(f) {
+			changes = append(changes, fmt.Sprintf("finalizer %s removed, %s remaining", f, describeFinalizers(remaining)))
+		}
+	}
+	return changes
+}
+
+func describeFinalizers(finalizers []string) string {
+	if len(finalizers) == 0 {
+		return "no finalizers"
+	}
+	return "finalizers " + strings.Join(finalizers, ", ")
+}
+
+// propagation infers the deletion propagation policy from the finalizers the
+// garbage collector adds for foreground and orphan deletion.
+func propagation(finalizers []string) metav1.DeletionPropagation {
+	for _, f := range finalizers {
+		switch f {
+		case metav1.FinalizerDeleteDependents:
+			return metav1.DeletePropagationForeground
+		case metav1.FinalizerOrphanDependents:
+			return metav1.DeletePropagationOrphan
+		}
+	}
+	return metav1.DeletePropagationBackground
+}
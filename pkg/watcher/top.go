@@ -0,0 +1,104 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var (
+	topN = pflag.Int("top", 0, "At exit, print the N objects and field paths that changed most often to stderr (0 disables)")
+
+	// changeCounts counts printed events when --top or --report-file is
+	// set. It is only used by the goroutine printing events.
+	changeCounts *changeCounter
+)
+
+type changeCounter struct {
+	events    int
+	types     map[string]int
+	resources map[string]int
+	objects   map[string]int
+	paths     map[string]int
+}
+
+func newChangeCounter() *changeCounter {
+	return &changeCounter{types: map[string]int{}, resources: map[string]int{}, objects: map[string]int{}, paths: map[string]int{}}
+}
+
+func (c *changeCounter) record(e *Event) {
+	c.events++
+	c.types[string(e.Type)]++
+	if !e.Resource.Empty() {
+		key := e.Resource.GroupVersion().String() + "/" + e.Resource.Resource
+		if e.Cluster != "" {
+			key = e.Cluster + "/" + key
+		}
+		c.resources[key]++
+	}
+	c.objects[e.FullName()]++
+	// Additions and deletions touch every field, only updates say which
+	// fields churn.
+	if e.Type != watch.Modified {
+		return
+	}
+	for _, p := range e.Paths {
+		c.paths[p.String()]++
+	}
+}
+
+func (c *changeCounter) write(w io.Writer, n int) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	writeTop(tw, "OBJECT", c.objects, n)
+	fmt.Fprintln(tw)
+	writeTop(tw, "FIELD", c.paths, n)
+	tw.Flush()
+}
+
+// writeTop prints the n keys of counts with the highest counts.
+func writeTop(w io.Writer, title string, counts map[string]int, n int) {
+	fmt.Fprintf(w, "%s\tCHANGES\n", title)
+	for _, k := range topKeys(counts, n) {
+		fmt.Fprintf(w, "%s\t%d\n", sanitize(k), counts[k])
+	}
+}
+
+// topKeys returns the n keys of counts with the highest counts, ties broken
+// by key.
+func topKeys(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+var typedCore = pflag.Bool("typed-core", false, "List and watch the busiest core resources, like pods, services and deployments, through the typed clients, which decode them faster. Fields newer than this build are dropped")
+
+// typedCoreResources are the resources --typed-core fetches through the typed
+// clients.
+var typedCoreResources = sets.New(
+	corev1.SchemeGroupVersion.WithResource("pods"),
+	corev1.SchemeGroupVersion.WithResource("services"),
+	corev1.SchemeGroupVersion.WithResource("endpoints"),
+	corev1.SchemeGroupVersion.WithResource("configmaps"),
+	corev1.SchemeGroupVersion.WithResource("secrets"),
+	corev1.SchemeGroupVersion.WithResource("events"),
+	corev1.SchemeGroupVersion.WithResource("nodes"),
+	corev1.SchemeGroupVersion.WithResource("namespaces"),
+	corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"),
+	corev1.SchemeGroupVersion.WithResource("persistentvolumes"),
+	appsv1.SchemeGroupVersion.WithResource("deployments"),
+	appsv1.SchemeGroupVersion.WithResource("replicasets"),
+	appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	batchv1.SchemeGroupVersion.WithResource("jobs"),
+	batchv1.SchemeGroupVersion.WithResource("cronjobs"),
+)
+
+// A resourceClient lists and watches one resource as unstructured objects.
+// It is the subset of dynamic.ResourceInterface the watch backend uses.
+type resourceClient interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// resource returns the client used to list and watch gvr. With
+// --metadata-only only object metadata is fetched. Otherwise built-in
+// resources are fetched as protobuf when --content-type asks for it, and
+// those of --typed-core are decoded by the typed clients. Everything else
+// (custom and aggregated resources) goes through the dynamic client as JSON.
+// Clusters created by NewCluster always use the dynamic client, and so do
+// group versions the typed path failed for.
+func (cl *Cluster) resource(gvr schema.GroupVersionResource) resourceClient {
+	if *metadataOnly && cl.cfg != nil {
+		r, err := cl.metadataResource(gvr)
+		if err == nil {
+			return r
+		}
+		klog.Warningf("fetching all of '%v', not just its metadata: %v", gvr, err)
+	}
+	protobuf := *contentType == runtime.ContentTypeProtobuf && scheme.Scheme.IsVersionRegistered(gvr.GroupVersion())
+	if cl.cfg == nil || !protobuf && !(*typedCore && typedCoreResources.Has(gvr)) {
+		return cl.dc.Resource(gvr)
+	}
+	c, err := cl.typedClient(gvr.GroupVersion())
+	if err != nil {
+		return cl.dc.Resource(gvr)
+	}
+	return &typedResource{cl: cl, gvr: gvr, client: c}
+}
+
+// typedClient returns the client of the typed objects of gv: a protobuf one
+// with --content-type set to it, else that of the kubernetes clientset.
+func (cl *Cluster) typedClient(gv schema.GroupVersion) (rest.Interface, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if c, ok := cl.typed[gv]; ok {
+		if c == nil {
+			return nil, fmt.Errorf("typed client failed for %v", gv)
+		}
+		return c, nil
+	}
+	var c rest.Interface
+	if *contentType == runtime.ContentTypeProtobuf {
+		cfg := rest.CopyConfig(cl.cfg)
+		cfg.GroupVersion = &gv
+		cfg.APIPath = "/apis"
+		if gv.Group == "" {
+			cfg.APIPath = "/api"
+		}
+		cfg.ContentType = runtime.ContentTypeProtobuf
+		cfg.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+		cfg.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+		var err error
+		if c, err = rest.RESTClientFor(cfg); err != nil {
+			return nil, err
+		}
+	} else {
+		if cl.client == nil {
+			return nil, fmt.Errorf("no typed client for %v", gv)
+		}
+		switch gv {
+		case corev1.SchemeGroupVersion:
+			c = cl.client.CoreV1().RESTClient()
+		case appsv1.SchemeGroupVersion:
+			c = cl.client.AppsV1().RESTClient()
+		case batchv1.SchemeGroupVersion:
+			c = cl.client.BatchV1().RESTClient()
+		default:
+			return nil, fmt.Errorf("no typed client for %v", gv)
+		}
+	}
+	if cl.typed == nil {
+		cl.typed = map[schema.GroupVersion]rest.Interface{}
+	}
+	cl.typed[gv] = c
+	return c, nil
+}
+
+// degradeTyped makes gv go through the dynamic client from now on after err,
+// which the typed path is alone to blame for.
+func (cl *Cluster) degradeTyped(gv schema.GroupVersion, err error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if c, ok := cl.typed[gv]; ok && c == nil {
+		return
+	}
+	klog.Warningf("falling back to the dynamic client for %v: %v", gv, err)
+	cl.typed[gv] = nil
+}
+
+// A typedError is an error decoding a response into typed objects or
+// converting those to unstructured ones.
+type typedError struct {
+	err error
+}
+
+func (e *typedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *typedError) Unwrap() error {
+	return e.err
+}
+
+// typedFailed reports whether err means that the typed path, rather than the
+// request, failed: the server refused the content type, or the response could
+// not be decoded or converted.
+func typedFailed(err error) bool {
+	var typedErr *typedError
+	return goerrors.As(err, &typedErr) || errors.IsUnsupportedMediaType(err) || errors.IsNotAcceptable(err)
+}
+
+type typedResource struct {
+	cl     *Cluster
+	gvr    schema.GroupVersionResource
+	client rest.Interface
+}
+
+func (r *typedResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, err := r.list(ctx, opts)
+	if err != nil && typedFailed(err) && ctx.Err() == nil {
+		r.cl.degradeTyped(r.gvr.GroupVersion(), err)
+		return r.cl.dc.Resource(r.gvr).List(ctx, opts)
+	}
+	return list, err
+}
+
+func (r *typedResource) list(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	body, err := r.client.Get().Resource(r.gvr.Resource).VersionedParams(&opts, scheme.ParameterCodec).Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := runtime.Decode(scheme.Codecs.UniversalDeserializer(), body)
+	if err != nil {
+		return nil, &typedError{err}
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, &typedError{err}
+	}
+	listMeta, err := meta.ListAccessor(obj)
+	if err != nil {
+		return nil, &typedError{err}
+	}
+
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	list.SetResourceVersion(listMeta.GetResourceVersion())
+	list.SetContinue(listMeta.GetContinue())
+	list.Items = make([]unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		u, err := toUnstructured(item)
+		if err != nil {
+			return nil, &typedError{err}
+		}
+		list.Items = append(list.Items, *u)
+	}
+	return list, nil
+}
+
+func (r *typedResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	typedOpts := opts
+	typedOpts.Watch = true
+	w, err := r.client.Get().Resource(r.gvr.Resource).VersionedParams(&typedOpts, scheme.ParameterCodec).Watch(ctx)
+	if err != nil && typedFailed(err) && ctx.Err() == nil {
+		r.cl.degradeTyped(r.gvr.GroupVersion(), err)
+		return r.cl.dc.Resource(r.gvr).Watch(ctx, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The watch fails on the errors below and is restarted through the
+	// dynamic client.
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		if e.Type == watch.Error {
+			if undecodableEvent(errors.FromObject(e.Object)) {
+				r.cl.degradeTyped(r.gvr.GroupVersion(), errors.FromObject(e.Object))
+			}
+			return e, true
+		}
+		u, err := toUnstructured(e.Object)
+		if err != nil {
+			r.cl.degradeTyped(r.gvr.GroupVersion(), err)
+			return watch.Event{Type: watch.Error, Object: &metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}}, true
+		}
+		e.Object = u
+		return e, true
+	}), nil
+}
+
+// toUnstructured converts a typed object, which lacks apiVersion and kind
+// once decoded, to the unstructured form the dynamic client returns.
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(gvks) == 0 {
+		return nil, fmt.Errorf("unknown kind of %T", obj)
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: m}
+	u.SetGroupVersionKind(gvks[0])
+	return u, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func podList(n int) *corev1.PodList {
+	list := &corev1.PodList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"}, ListMeta: metav1.ListMeta{ResourceVersion: "100"}}
+	for i := 0; i < n; i++ {
+		list.Items = append(list.Items, corev1.Pod{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("web-%d", i),
+				Namespace:         "default",
+				Labels:            map[string]string{"app": "web"},
+				ResourceVersion:   "100",
+				CreationTimestamp: metav1.Unix(1700000000, 0),
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Containers: []corev1.Container{{
+					Name:  "web",
+					Image: "nginx:1.25",
+					Ports: []corev1.ContainerPort{{ContainerPort: 80, Protocol: corev1.ProtocolTCP}},
+					Env:   []corev1.EnvVar{{Name: "MODE", Value: "production"}},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				PodIP:             "10.0.0.1",
+				Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "web", Ready: true, RestartCount: 1, Image: "nginx:1.25"}},
+			},
+		})
+	}
+	return list
+}
+
+// newPodServer returns a cluster whose API server lists pods, as protobuf
+// when asked for it and as JSON otherwise.
+func newPodServer(t testing.TB, pods *corev1.PodList) *Cluster {
+	encoded := map[string][]byte{}
+	for _, mediaType := range []string{runtime.ContentTypeJSON, runtime.ContentTypeProtobuf} {
+		info, _ := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+		data, err := runtime.Encode(info.Serializer, pods)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded[mediaType] = data
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		mediaType := runtime.ContentTypeJSON
+		if strings.HasPrefix(r.Header.Get("Accept"), runtime.ContentTypeProtobuf) {
+			mediaType = runtime.ContentTypeProtobuf
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(encoded[mediaType])
+	}))
+	t.Cleanup(srv.Close)
+
+	// No client-side throttling, which would dominate the benchmarks.
+	cfg := &rest.Config{Host: srv.URL, QPS: -1}
+	c, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cluster{name: "test", cfg: cfg, client: c, dc: dc}
+}
+
+// withClientFlags sets --content-type and --typed-core for the duration of
+// a test.
+func withClientFlags(t testing.TB, content string, typed bool) {
+	prevContent, prevTyped := *contentType, *typedCore
+	*contentType, *typedCore = content, typed
+	t.Cleanup(func() { *contentType, *typedCore = prevContent, prevTyped })
+}
+
+func TestTypedResource(t *testing.T) {
+	cl := newPodServer(t, podList(3))
+	want, err := cl.dc.Resource(podsResource).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{runtime.ContentTypeJSON, runtime.ContentTypeProtobuf} {
+		withClientFlags(t, content, true)
+		r := cl.resource(podsResource)
+		if _, ok := r.(*typedResource); !ok {
+			t.Fatalf("%s: expected the typed path for pods, got %T", content, r)
+		}
+		got, err := r.List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetResourceVersion() != want.GetResourceVersion() {
+			t.Errorf("%s: resourceVersion %q, want %q", content, got.GetResourceVersion(), want.GetResourceVersion())
+		}
+		if !reflect.DeepEqual(got.Items, want.Items) {
+			t.Errorf("%s: typed list differs from the dynamic one:\n%v\n%v", content, got.Items, want.Items)
+		}
+	}
+
+	withClientFlags(t, runtime.ContentTypeJSON, false)
+	if r := cl.resource(podsResource); reflect.TypeOf(r) == reflect.TypeOf(&typedResource{}) {
+		t.Errorf("expected the dynamic client without --typed-core, got %T", r)
+	}
+}
+
+func TestTypedFailed(t *testing.T) {
+	gr := schema.GroupResource{Resource: "pods"}
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&typedError{fmt.Errorf("proto: illegal wireType")}, true},
+		{fmt.Errorf("listing: %w", &typedError{fmt.Errorf("unknown kind")}), true},
+		{errors.NewGenericServerResponse(http.StatusUnsupportedMediaType, "list", gr, "", "", 0, false), true},
+		{errors.NewGenericServerResponse(http.StatusNotAcceptable, "list", gr, "", "", 0, false), true},
+		{errors.NewInternalError(fmt.Errorf("etcd unavailable")), false},
+		{errors.NewForbidden(gr, "", fmt.Errorf("denied")), false},
+		{fmt.Errorf("unexpected response"), false},
+		{context.DeadlineExceeded, false},
+	} {
+		if got := typedFailed(tc.err); got != tc.want {
+			t.Errorf("typedFailed(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func benchmarkList(b *testing.B, content string, typed bool) {
+	withClientFlags(b, content, typed)
+	cl := newPodServer(b, podList(500))
+	r := cl.resource(podsResource)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.List(context.Background(), metav1.ListOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListDynamic(b *testing.B) {
+	benchmarkList(b, runtime.ContentTypeJSON, false)
+}
+
+func BenchmarkListTypedJSON(b *testing.B) {
+	benchmarkList(b, runtime.ContentTypeJSON, true)
+}
+
+func BenchmarkListTypedProtobuf(b *testing.B) {
+	benchmarkList(b, runtime.ContentTypeProtobuf, true)
+}
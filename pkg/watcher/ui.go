@@ -0,0 +1,218 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/klog/v2"
+)
+
+var uiAddr = pflag.String("ui-addr", "", "Serve a web page streaming the printed events, filterable by namespace, kind and verb, on this address, e.g. localhost:8080")
+
+// uiClientBuffer is how many events a browser may fall behind by before
+// further ones are dropped for it.
+const uiClientBuffer = 100
+
+// eventUI is the server of --ui-addr, nil without it.
+var eventUI *uiServer
+
+// A uiServer fans the printed events out to the browsers streaming them.
+type uiServer struct {
+	stopCh <-chan struct{}
+
+	mu      sync.Mutex
+	clients map[chan []byte]bool
+}
+
+// uiEvent is how an event is sent to browsers.
+type uiEvent struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Name      string    `json:"name"`
+	Verb      string    `json:"verb"`
+	Diff      string    `json:"diff"`
+}
+
+func newUIServer(addr string, stopCh <-chan struct{}) (*uiServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &uiServer{stopCh: stopCh, clients: map[chan []byte]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.page)
+	mux.HandleFunc("/events", s.stream)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			klog.Error("error serving UI: ", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	return s, nil
+}
+
+// publish sends e to every connected browser.
+func (s *uiServer) publish(e *Event) {
+	u := uiEvent{
+		Seq:     e.Seq,
+		Time:    e.Timestamp,
+		Cluster: e.Cluster,
+		Name:    e.Name,
+		Verb:    auditVerbs[e.Type],
+		Diff:    stripColors(e.Data),
+	}
+	if e.Object != nil {
+		u.Namespace, u.Kind, u.Name = e.Object.GetNamespace(), e.Object.GetKind(), e.Object.GetName()
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		klog.Error("error encoding event: ", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- data:
+		default:
+			klog.V(2).Info("UI client too slow, dropping event")
+		}
+	}
+}
+
+func (s *uiServer) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan []byte, uiClientBuffer)
+	s.mu.Lock()
+	s.clients[c] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-r.Context().Done():
+			return
+		case data := <-c:
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *uiServer) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, uiPage)
+}
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>kubectl-watch</title>
+<style>
+body { font-family: sans-serif; margin: 0; }
+header { position: sticky; top: 0; background: #eee; padding: 8px; }
+input { margin-right: 12px; }
+.event { border-bottom: 1px solid #ddd; padding: 4px 8px; }
+.head { font-weight: bold; }
+.create .head { color: #080; }
+.delete .head { color: #a00; }
+pre { margin: 4px 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<header>
+Namespace <input id="namespace">
+Kind <input id="kind">
+Verb <input id="verb" placeholder="create, update or delete">
+<span id="status"></span>
+</header>
+<div id="events"></div>
+<script>
+const filters = ["namespace", "kind", "verb"];
+function shown(e) {
+  return filters.every(f => {
+    const want = document.getElementById(f).value.trim().toLowerCase();
+    return want === "" || (e[f] || "").toLowerCase() === want;
+  });
+}
+function refilter() {
+  for (const div of document.getElementById("events").children) {
+    div.hidden = !shown(div.event);
+  }
+}
+for (const f of filters) {
+  document.getElementById(f).addEventListener("input", refilter);
+}
+const source = new EventSource("events");
+source.onopen = () => { document.getElementById("status").textContent = "connected"; };
+source.onerror = () => { document.getElementById("status").textContent = "disconnected"; };
+source.onmessage = msg => {
+  const e = JSON.parse(msg.data);
+  const div = document.createElement("div");
+  div.className = "event " + e.verb;
+  div.event = e;
+  const head = document.createElement("div");
+  head.className = "head";
+  const where = [e.cluster, e.namespace].filter(s => s).join("/");
+  head.textContent = "#" + e.seq + " " + new Date(e.time).toLocaleTimeString() + " " + e.verb + " " +
+    (e.kind ? e.kind + " " : "") + (where ? where + "/" : "") + e.name;
+  const diff = document.createElement("pre");
+  diff.textContent = e.diff;
+  div.append(head, diff);
+  div.hidden = !shown(e);
+  document.getElementById("events").prepend(div);
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+var includeUnknownEvents = pflag.Bool("include-unknown-events", false, "Also print the watch events that are not about objects, such as ERROR ones and those of unknown types, with the object the server sent as is, instead of dropping them")
+
+func validateUnknownEvents() error {
+	if *includeUnknownEvents && *backend == "informer" {
+		return fmt.Errorf("--include-unknown-events is not supported with the informer backend, which handles such events itself")
+	}
+	return nil
+}
+
+// knownEvent reports whether t is one of the types of watch events about
+// objects.
+func knownEvent(t watch.EventType) bool {
+	switch t {
+	case watch.Added, watch.Modified, watch.Deleted, watch.Bookmark:
+		return true
+	}
+	return false
+}
+
+// unknownEvent returns the diagnostic event printed for an event of an
+// unknown type or an ERROR one with --include-unknown-events. It is named
+// after the object sent if it has a name, else after the watched resource.
+func unknownEvent(gvr schema.GroupVersionResource, event watch.Event) *Event {
+	o := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	switch obj := event.Object.(type) {
+	case nil:
+	case *unstructured.Unstructured:
+		o = obj
+	default:
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			klog.Errorf("error converting %s event object %T: %v", event.Type, obj, err)
+			return nil
+		}
+		o.Object = m
+		// Errors built client side lack the kind of the Status they are.
+		if _, ok := obj.(*metav1.Status); ok && o.GetKind() == "" {
+			o.SetAPIVersion("v1")
+			o.SetKind("Status")
+		}
+	}
+	key := gvr.GroupVersion().String() + "/" + gvr.Resource
+	if o.GetName() != "" {
+		key = getKey(o)
+	}
+	return rawEvent(Now(), key, event.Type, o)
+}
@@ -0,0 +1,1513 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skaslev/kubectl-watch/pkg/logging"
+	"github.com/skaslev/kubectl-watch/pkg/signals"
+
+	"github.com/spf13/pflag"
+	"github.com/yudai/gojsondiff"
+	"github.com/yudai/gojsondiff/formatter"
+	"golang.org/x/term"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultListConcurrency = 4
+	configQPSPerLister     = 6
+	configBurst            = 100
+)
+
+var (
+	masterURL             = pflag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	kubeconfigs           = pflag.StringSlice("kubeconfig", nil, "Coma separated list of kubeconfig paths, each watched as a separate cluster, - reads one from stdin. Only required if out-of-cluster.")
+	contexts              = pflag.StringSlice("context", nil, "Coma separated list of kubeconfig contexts, each watched as a separate cluster")
+	colorize              = pflag.BoolP("color", "c", true, "Colorize the output")
+	outFormat             = pflag.StringP("out", "o", "", "Output format: side-by-side, trace, json, ndjson, delta, audit or kubectl-diff (default diffs). delta is ndjson with the diff as a nested JSON delta. audit prints the complete objects before and after every change, which is verbose, so narrow down what is watched with the filter flags")
+	namespaces            = pflag.StringSliceP("namespace", "n", nil, "Coma separated list of namespaces to watch")
+	groupVersions         = pflag.StringSliceP("group-version", "g", nil, "Coma separated list of GroupVersions to watch")
+	apiGroups             = pflag.StringSlice("group", nil, "Coma separated list of API groups to watch in any version, e.g. apps (core selects the legacy core group)")
+	groupVersionResources = pflag.StringSliceP("group-version-resource", "r", nil, "Coma separated list of GroupVersionResources to watch, or resource categories such as all")
+	labelSelector         = pflag.StringP("selector", "l", "", "Label selector to filter objects on the server")
+	fieldSelector         = pflag.String("field-selector", "", "Field selector to filter objects on the server")
+	onlyKinds             = pflag.StringSlice("only-kinds", nil, "Coma separated list of kinds to watch regardless of their group and version, e.g. Pod,Deployment")
+	logFormat             = pflag.String("log-format", "", "Format of the tool's own diagnostic logs: logfmt or json (default klog's text format)")
+	listConcurrency       = pflag.Int("list-concurrency", defaultListConcurrency, "Number of resources listed in parallel during the initial sync")
+	maxWidth              = pflag.Int("max-width", 0, "Wrap output to this many columns (defaults to the terminal width)")
+	statusToStderr        = pflag.Bool("status-to-stderr", false, "Print changes that only touch status to stderr instead of the output")
+	rawOutput             = pflag.String("raw", "", "Print every watch event in full instead of diffs: compact (one line of JSON) or pretty (see --object-format)")
+	resyncInterval        = pflag.Duration("resync-interval", 0, "Periodically re-list each resource and restart its watch to catch missed changes (0 disables)")
+	pollInterval          = pflag.Duration("poll-interval", 0, "List resources that cannot be watched at this interval and print their changes (0 skips them)")
+	compactJSON           = pflag.Bool("compact-json", false, "Pack trace output without whitespace between events")
+	orderedSync           = pflag.Bool("ordered-sync", false, "Hold back events until the initial sync completes and print them sorted by name")
+	firstEventTimeout     = pflag.Duration("first-event-timeout", 0, "Exit with an error if no event is printed within this long after the initial sync (0 disables)")
+	startupStagger        = pflag.Duration("startup-stagger", 10*time.Millisecond, "Delay, with up to 50% jitter, between starting the watchers of two resources, to spread their initial lists")
+	listRetries           = pflag.Int("list-retries", 3, "Number of times the initial list of a resource is retried with backoff after transient errors")
+	listPageSize          = pflag.Int64("list-page-size", 500, "Number of objects requested per page when listing resources (0 lists everything at once)")
+	showSeq               = pflag.Bool("show-seq", false, "Show the sequence number of every event in its header. Structured output always includes it")
+	showResourceVersion   = pflag.Bool("show-resource-version", false, "Show the resourceVersion of the object of every event in its header and structured output")
+	annotateNode          = pflag.Bool("output-annotate-node", false, "Show the node of pods, their spec.nodeName, in the header of every event as node=<name>, and in structured output")
+	showLabels            = pflag.StringSlice("show-labels", nil, "Coma separated list of label keys whose values are shown in the header of every event, like kubectl get -L")
+	relativeTime          = pflag.Bool("relative-time", false, "Show the time elapsed since the start in event headers instead of the wall clock time. Structured output always has absolute times")
+	diffAgainst           = pflag.String("diff-against", "previous", "What updates are diffed against: the previous version of the object or the first one seen during the run")
+	showNoopUpdates       = pflag.Bool("show-noop-updates", false, "Show updates that only change resourceVersion and managedFields timestamps")
+	resourceVersion       = pflag.String("resource-version", "", "List every resource at this resourceVersion at startup (see --resource-version-match)")
+	resourceVersionMatch  = pflag.String("resource-version-match", "", "How --resource-version is interpreted by the initial list: NotOlderThan or Exact (default NotOlderThan)")
+	failOnError           = pflag.Bool("fail-on-error", false, "Exit with an error when a resource cannot be listed or watched instead of giving up on it (resources that are not found or cannot be watched are still skipped)")
+	generationOnly        = pflag.Bool("watch-generation-only", false, "Show updates only when metadata.generation increases, i.e. the desired state changed (resources without a generation only show additions and deletions)")
+	createdOnly           = pflag.Bool("created-only", false, "Show only the additions of objects created after the start, by their creationTimestamp, hiding updates, deletions and objects that existed already")
+
+	// Now returns the time events are stamped with. Tests can replace it
+	// to get deterministic output.
+	Now = time.Now
+
+	namespaceFilter                   func(string) bool
+	groupFilter, gvFilter, kindFilter func(string) bool
+	gvrFilter                         func(string, metav1.APIResource) bool
+	emptyUnstructured                 = &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	stopOnce     sync.Once
+	stopChan     = make(chan struct{})
+	exitCode     int
+	firstPrinted = make(chan struct{})
+	// startTime is when Run started, to the second of creationTimestamps.
+	startTime time.Time
+	// eventSeq is the sequence number of the last printed event.
+	eventSeq uint64
+	// workers tracks the goroutines of Run that end along with it, which
+	// it waits for so that the next Run starts afresh.
+	workers sync.WaitGroup
+)
+
+// goWorker runs f in a goroutine tracked by workers.
+func goWorker(f func()) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		f()
+	}()
+}
+
+// prepareObject returns the copy of o that is diffed and cached, with
+// redactions applied and ignored fields removed.
+func prepareObject(o *unstructured.Unstructured) *unstructured.Unstructured {
+	o = copyObject(o)
+	redact(o.Object)
+	removeIgnoredFields(o.Object)
+	if len(setPathRules) != 0 {
+		sortSets(o.Object, nil)
+	}
+	restrictConfigMapKeys(o)
+	if *rawOutput == "" {
+		if !*showBinaryData {
+			describeBinaryData(o)
+		}
+		if *excludeNoisyAnnotations {
+			stripNoisyAnnotations(o)
+		}
+		if *ignoreWhitespace {
+			normalizeWhitespace(o.Object)
+		}
+	}
+	return o
+}
+
+func getKey(o *unstructured.Unstructured) string {
+	var buf strings.Builder
+	if ns := o.GetNamespace(); len(ns) != 0 {
+		buf.WriteString(ns)
+		buf.WriteByte('/')
+	}
+	buf.WriteString(o.GetName())
+	buf.WriteByte(' ')
+	if api := o.GetAPIVersion(); len(api) != 0 {
+		buf.WriteString(api)
+		buf.WriteByte('/')
+	}
+	buf.WriteString(strings.ToLower(o.GetKind()))
+	return buf.String()
+}
+
+func processEvent(event watch.Event, cache *objectCache) *Event {
+	e := diffEvent(event, cache)
+	if e != nil && *showResourceVersion {
+		// Read off the watched object, as --spec-only prunes it from the
+		// diffed one.
+		e.ResourceVersion = event.Object.(*unstructured.Unstructured).GetResourceVersion()
+	}
+	// Read off the diffed object, which is redacted.
+	if e != nil && e.Object != nil && len(*showLabels) != 0 {
+		e.Labels = e.Object.GetLabels()
+	}
+	if e != nil && e.Object != nil && *annotateNode {
+		e.Node, _, _ = unstructured.NestedString(e.Object.Object, "spec", "nodeName")
+	}
+	return e
+}
+
+// diffEvent returns the event describing the change event makes to the
+// object in cache, or nil if it is filtered out.
+func diffEvent(event watch.Event, cache *objectCache) *Event {
+	if !knownEvent(event.Type) {
+		return nil
+	}
+
+	o, ok := event.Object.(*unstructured.Unstructured)
+	if !ok {
+		klog.Warningf("skipping %s event of unexpected type %T", event.Type, event.Object)
+		return nil
+	}
+	if !namespaceFilter(o.GetNamespace()) || excludedName(o.GetName()) || tooLarge(o) {
+		return nil
+	}
+	matched := matchesWhere(o)
+	now := eventTime(event.Type, o, Now())
+	new := prepareObject(o)
+
+	key := getKey(new)
+	if *rawOutput != "" {
+		if !matched {
+			return nil
+		}
+		return rawEvent(now, key, event.Type, new)
+	}
+
+	summarize := summarizerOf(new)
+	prev, ok := cache.get(key)
+	if !ok {
+		prev = emptyUnstructured
+	}
+	old := cache.baseline(key, prev)
+	obj := new
+	if event.Type == watch.Deleted {
+		old, new = new, emptyUnstructured
+		cache.remove(key)
+	} else {
+		cache.set(key, new)
+	}
+	if !matched {
+		// Still cached above, so the diff shows how the object got to
+		// match once it does.
+		return nil
+	}
+	if *createdOnly && (event.Type != watch.Added || o.GetCreationTimestamp().Time.Before(startTime)) {
+		return nil
+	}
+
+	if event.Type == watch.Modified && summarize == nil && streamsDiff(new) {
+		return streamedDiffEvent(now, key, obj, old, new)
+	}
+
+	d := newObjectDiff(old, new)
+	if !keepDiff(event.Type, prev, d) {
+		return nil
+	}
+
+	if *fullOnDelete && event.Type == watch.Deleted {
+		if len(prev.Object) == 0 {
+			prev = obj
+		}
+		return deletedObjectEvent(now, key, prev)
+	}
+	return renderEvent(now, key, event.Type, obj, d, summarize)
+}
+
+// keepDiff reports whether the change d of an object of eventType passes the
+// diff filters. prev is the previous state of the object, which differs from
+// d.old with --diff-against first.
+func keepDiff(eventType watch.EventType, prev *unstructured.Unstructured, d *objectDiff) bool {
+	if !d.diff.Modified() {
+		return false
+	}
+	old, new, paths := d.old, d.new, d.paths
+	if !*showNoopUpdates && eventType == watch.Modified && isNoopUpdate(paths) {
+		return false
+	}
+	if !*showNoopUpdates && eventType == watch.Modified && old != prev {
+		// With --diff-against first, updates that only repeat the drift
+		// from the baseline are no-ops too.
+		drift := gojsondiff.New().CompareObjects(diffForms(prev, new))
+		if !drift.Modified() || isNoopUpdate(changedPaths(drift.Deltas())) {
+			return false
+		}
+	}
+	if *labelsAnnotationsOnly && eventType == watch.Modified && !touchesLabelsOrAnnotations(paths) {
+		// managedFields are kept for --human-changes-only and
+		// --show-manager-diff, but changes to them alone don't count.
+		return false
+	}
+	// Against the previous state, not the --diff-against first baseline.
+	if *generationOnly && eventType == watch.Modified && new.GetGeneration() <= prev.GetGeneration() {
+		return false
+	}
+
+	if *humanChangesOnly {
+		if len(new.Object) == 0 || !matchManagers(changeManagers(old, d.compared, paths), *humanManagers) {
+			return false
+		}
+	}
+	if len(*excludeManagedBy) != 0 && eventType == watch.Modified && allManagersMatch(changeManagers(old, d.compared, paths), *excludeManagedBy) {
+		return false
+	}
+	if *serverSideApplyOnly && eventType == watch.Modified && !onlyAppliedChange(old, d.compared, paths) {
+		return false
+	}
+	return true
+}
+
+// An objectDiff is the difference between two states of an object.
+type objectDiff struct {
+	old, new *unstructured.Unstructured
+	// compared is new with its arrays aligned to those of old.
+	compared     *unstructured.Unstructured
+	base, target map[string]interface{}
+	diff         gojsondiff.Diff
+	paths        []fieldPath
+}
+
+func newObjectDiff(old, new *unstructured.Unstructured) *objectDiff {
+	d := &objectDiff{old: old, new: new, compared: new}
+	if aligned, ok := alignArrays(old.Object, new.Object, nil); ok {
+		d.compared = &unstructured.Unstructured{Object: aligned.(map[string]interface{})}
+	}
+	d.base, d.target = diffForms(old, d.compared)
+	d.diff = gojsondiff.New().CompareObjects(d.base, d.target)
+	d.paths = changedPaths(d.diff.Deltas())
+	return d
+}
+
+// renderEvent returns the event describing d, summarized by summarize if it
+// is not nil.
+func renderEvent(now time.Time, key string, eventType watch.EventType, obj *unstructured.Unstructured, d *objectDiff, summarize summarizer) *Event {
+	old, new := d.old, d.new
+	if summarize != nil {
+		text := summarize(old, new)
+		if len(text) == 0 {
+			return nil
+		}
+		return &Event{Timestamp: now, Name: key, Data: text, Type: eventType, Object: obj, Old: old, Paths: d.paths, Summary: summarizeDeltas(d.diff.Deltas())}
+	}
+
+	if *collapseArrays {
+		collapseScalarArrays(d.diff.Deltas(), d.base, d.target)
+	}
+	formatter := formatter.NewAsciiFormatter(d.base, formatter.AsciiFormatterConfig{Coloring: *colorize || restyled()})
+	text, err := formatter.Format(d.diff)
+	if err != nil {
+		klog.Error("error formatting diff: ", err)
+		return nil
+	}
+	text = restyleDiff(text)
+	if eventType == watch.Modified {
+		text = prependNotes(text, terminationChanges(old, new))
+		if *showManagerDiff {
+			text = prependNotes(text, managerChanges(old, new))
+		}
+		if *highlightOwnership {
+			text = highlightOwnershipChanges(text, old, new, d.paths)
+		}
+		if *highlightConditions {
+			text = highlightConditionChanges(text, old, new)
+		}
+	}
+	if *showOwnershipTable && eventType != watch.Deleted {
+		text = prependNotes(text, ownershipTable(new))
+	}
+
+	return &Event{Timestamp: now, Name: key, Data: text, Type: eventType, Object: obj, Old: old, Paths: d.paths, Summary: summarizeDeltas(d.diff.Deltas())}
+}
+
+func rawEvent(now time.Time, key string, eventType watch.EventType, o *unstructured.Unstructured) *Event {
+	var data string
+	if *rawOutput == "pretty" {
+		text, err := formatObject(o)
+		if err != nil {
+			klog.Error("error encoding object: ", err)
+			return nil
+		}
+		data = fmt.Sprintf("%s\n%s", eventType, text)
+	} else {
+		b, err := json.Marshal(o.Object)
+		if err != nil {
+			klog.Error("error encoding object: ", err)
+			return nil
+		}
+		data = fmt.Sprintf("%s %s", eventType, b)
+	}
+	return &Event{Timestamp: now, Name: key, Data: data, Type: eventType, Object: o}
+}
+
+// A watchEnd is why processEvents returned.
+type watchEnd int
+
+const (
+	// watchStopped means the watcher is shutting down.
+	watchStopped watchEnd = iota
+	// watchResync means --resync-interval elapsed.
+	watchResync
+	// watchClosed means the server closed the watch, which it does
+	// routinely after a timeout.
+	watchClosed
+	// watchFailed means the server sent an error.
+	watchFailed
+)
+
+// processEvents consumes in until the watch ends and returns why it did,
+// along with the error sent by the server for watchFailed.
+func processEvents(cl *Cluster, gvr schema.GroupVersionResource, in <-chan watch.Event, out chan<- *Event, cache *objectCache, sample *sampler, health *watcherHealth, resync <-chan time.Time, stopCh <-chan struct{}) (watchEnd, error) {
+	for {
+		select {
+		case <-stopCh:
+			return watchStopped, nil
+		case <-resync:
+			return watchResync, nil
+		case <-memory.pressure():
+			cache.compact()
+		case event, ok := <-in:
+			if !ok {
+				return watchClosed, nil
+			}
+			if *includeUnknownEvents && !knownEvent(event.Type) {
+				if e := unknownEvent(gvr, event); e != nil {
+					e.Cluster = cl.label
+					e.Resource = gvr
+					out <- e
+				}
+			}
+			if event.Type == watch.Error {
+				return watchFailed, errors.FromObject(event.Object)
+			}
+			health.observed()
+			if o, ok := event.Object.(*unstructured.Unstructured); ok && watchCursor != nil {
+				watchCursor.set(cursorKey(cl, gvr), o.GetResourceVersion())
+			}
+			if event.Type == watch.Bookmark {
+				cache.compact()
+				continue
+			}
+			e := processEvent(event, cache)
+			if e != nil && sample.keep(e) {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+	}
+}
+
+func watchResource(cl *Cluster, gvr schema.GroupVersionResource, out chan<- *Event, cache *objectCache, stopCh <-chan struct{}) {
+	lastSync := time.Now()
+	backoff := newErrorBackoff()
+	sample := newSampler(cl, gvr)
+	health := healthOf(cl, gvr)
+	defer health.set(watcherStopped)
+	for {
+		var w watch.Interface
+		err := wait.PollImmediateUntil(time.Second, func() (done bool, err error) {
+			opts := listOptions()
+			opts.AllowWatchBookmarks = *lowMemory
+			if watchCursor != nil {
+				opts.ResourceVersion = watchCursor.get(cursorKey(cl, gvr))
+			}
+			klog.V(4).Infof("watching '%v' from resourceVersion %q", gvr, opts.ResourceVersion)
+			w, err = cl.resource(gvr).Watch(context.Background(), opts)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					return false, nil
+				}
+				if errors.IsResourceExpired(err) || errors.IsGone(err) {
+					resyncResource(cl, gvr, out, cache, stopCh)
+					lastSync = time.Now()
+					return false, nil
+				}
+				if errors.IsTooManyRequests(err) {
+					delay := retryDelay(&backoff, err)
+					klog.Warningf("watch of '%v' throttled, retrying in %v", gvr, delay)
+					health.set(watcherReconnecting)
+					select {
+					case <-stopCh:
+					case <-time.After(delay):
+					}
+					return false, nil
+				}
+				return false, err
+			}
+			return true, nil
+		}, stopCh)
+		if err != nil {
+			if errors.IsMethodNotSupported(err) && *pollInterval > 0 {
+				health.set(watcherPolling)
+				pollResource(cl, gvr, out, cache, stopCh)
+			} else if err != wait.ErrWaitTimeout && !errors.IsMethodNotSupported(err) {
+				klog.Errorf("error watching resources '%v': %v", gvr, err)
+				failOn(err)
+			}
+			return
+		}
+		health.set(watcherConnected)
+
+		var resync <-chan time.Time
+		if *resyncInterval > 0 {
+			resync = time.After(time.Until(lastSync.Add(*resyncInterval)))
+		}
+
+		started := time.Now()
+		end, err := processEvents(cl, gvr, w.ResultChan(), out, cache, sample, health, resync, stopCh)
+		w.Stop()
+		switch end {
+		case watchStopped:
+			return
+		case watchResync:
+			klog.V(2).Infof("resyncing '%v' after %v", gvr, *resyncInterval)
+		case watchClosed:
+			klog.V(2).Infof("server closed watch of '%v' after %v, reconnecting", gvr, time.Since(started).Round(time.Second))
+		}
+
+		if err != nil {
+			if errors.IsResourceExpired(err) || errors.IsGone(err) {
+				klog.Warningf("watch of '%v' failed after %v: expired resourceVersion, relisting: %v", gvr, time.Since(started).Round(time.Second), err)
+				resyncResource(cl, gvr, out, cache, stopCh)
+				lastSync = time.Now()
+				continue
+			}
+			if undecodableEvent(err) {
+				// Relisting moves the watch past the event instead of
+				// failing on it again.
+				klog.Warningf("skipping an event of '%v' that failed to decode, relisting: %v", gvr, err)
+				resyncResource(cl, gvr, out, cache, stopCh)
+				lastSync = time.Now()
+				continue
+			}
+			delay := retryDelay(&backoff, err)
+			klog.Warningf("watch of '%v' failed after %v: %s: %v, retrying in %v", gvr, time.Since(started).Round(time.Second), errors.ReasonForError(err), err, delay)
+			health.set(watcherReconnecting)
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		backoff = newErrorBackoff()
+
+		if *resyncInterval > 0 && time.Since(lastSync) >= *resyncInterval {
+			resyncResource(cl, gvr, out, cache, stopCh)
+			lastSync = time.Now()
+		}
+	}
+}
+
+// failOn stops the process with --fail-on-error unless err only means that
+// the resource is not served or cannot be watched, and reports whether it did.
+func failOn(err error) bool {
+	if !*failOnError || errors.IsNotFound(err) || errors.IsMethodNotSupported(err) {
+		return false
+	}
+	stop(1)
+	return true
+}
+
+// retryDelay returns the next step of backoff, extended to the delay the
+// server asked for in a Retry-After header if it is longer.
+func retryDelay(backoff *wait.Backoff, err error) time.Duration {
+	delay := backoff.Step()
+	if seconds, ok := errors.SuggestsClientDelay(err); ok {
+		delay = max(delay, time.Duration(seconds)*time.Second)
+	}
+	return delay
+}
+
+func newErrorBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      time.Minute,
+	}
+}
+
+// resyncResource re-lists gvr and feeds the result through processEvent as if
+// it came from the watch, so changes missed between reconnects (including
+// deletions, which a restarted watch never replays) are emitted. Objects
+// still at their cached resourceVersion are skipped, as they did not change
+// and raw output would otherwise print them again.
+func resyncResource(cl *Cluster, gvr schema.GroupVersionResource, out chan<- *Event, cache *objectCache, stopCh <-chan struct{}) {
+	seen := map[string]bool{}
+	unchanged := 0
+	rv, err := listResource(cl, gvr, listOptions(), func(objs *unstructured.UnstructuredList) {
+		for i := range objs.Items {
+			o := &objs.Items[i]
+			key := getKey(o)
+			seen[key] = true
+			eventType := watch.Modified
+			if prev, ok := cache.get(key); !ok {
+				eventType = watch.Added
+			} else if v := o.GetResourceVersion(); v != "" && v == prev.GetResourceVersion() {
+				unchanged++
+				continue
+			}
+			if e := processEvent(watch.Event{Type: eventType, Object: o}, cache); e != nil {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+	}, stopCh)
+	if err != nil {
+		klog.Errorf("error resyncing resources '%v': %v", gvr, err)
+		// The next resync or poll retries transient errors, as the watch
+		// does.
+		if !isTransientListError(err) {
+			failOn(err)
+		}
+		return
+	}
+	if watchCursor != nil {
+		watchCursor.set(cursorKey(cl, gvr), rv)
+	}
+	klog.V(4).Infof("resynced '%v': %d of %d objects unchanged", gvr, unchanged, len(seen))
+
+	for _, key := range cache.keys() {
+		if seen[key] {
+			continue
+		}
+		o, _ := cache.get(key)
+		if e := processEvent(watch.Event{Type: watch.Deleted, Object: o}, cache); e != nil {
+			e.Cluster = cl.label
+			e.Resource = gvr
+			out <- e
+		}
+		cache.remove(key)
+	}
+}
+
+// pollResource resyncs gvr every --poll-interval for resources that can be
+// listed but not watched.
+func pollResource(cl *Cluster, gvr schema.GroupVersionResource, out chan<- *Event, cache *objectCache, stopCh <-chan struct{}) {
+	klog.V(2).Infof("'%v' cannot be watched, polling it every %v", gvr, *pollInterval)
+	ticker := time.NewTicker(*pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			resyncResource(cl, gvr, out, cache, stopCh)
+		}
+	}
+}
+
+// listOptions returns the options shared by all list and watch requests.
+func listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: *labelSelector, FieldSelector: *fieldSelector}
+}
+
+// listResource lists gvr in pages of --list-page-size objects, passing each
+// page to fn, and returns the resourceVersion of the list. If the continue
+// token expires midway the remainder is fetched again in a single request,
+// so fn must tolerate seeing an object more than once.
+func listResource(cl *Cluster, gvr schema.GroupVersionResource, opts metav1.ListOptions, fn func(*unstructured.UnstructuredList), stopCh <-chan struct{}) (string, error) {
+	opts.Limit = *listPageSize
+	rv := ""
+	backoff := newErrorBackoff()
+	backoff.Steps = 5
+	for {
+		objs, err := cl.resource(gvr).List(context.Background(), opts)
+		if errors.IsTooManyRequests(err) && backoff.Steps > 1 {
+			delay := retryDelay(&backoff, err)
+			klog.Warningf("listing '%v' throttled, retrying in %v", gvr, delay)
+			select {
+			case <-stopCh:
+				return "", err
+			case <-time.After(delay):
+			}
+			continue
+		}
+		if err != nil {
+			if opts.Continue == "" || !errors.IsResourceExpired(err) {
+				return "", err
+			}
+			klog.Warningf("continue token for '%v' expired, listing the rest at once", gvr)
+			opts = listOptions()
+			rv = ""
+			continue
+		}
+		if rv == "" {
+			rv = objs.GetResourceVersion()
+		}
+		fn(objs)
+		if objs.GetContinue() == "" {
+			return rv, nil
+		}
+		opts.Continue = objs.GetContinue()
+		opts.ResourceVersion = ""
+		opts.ResourceVersionMatch = ""
+	}
+}
+
+// cacheResource lists gvr into a new cache. The objects modified since the
+// --since-last-restart reference time, and with --watch-namespaces-lifecycle
+// the namespaces being terminated, are returned instead, to be shown as
+// added.
+func cacheResource(cl *Cluster, gvr schema.GroupVersionResource, stopCh <-chan struct{}) (*objectCache, []*unstructured.Unstructured) {
+	cache := newObjectCache(cl, gvr)
+	var recent []*unstructured.Unstructured
+	addObject := func(o *unstructured.Unstructured) {
+		if !namespaceFilter(o.GetNamespace()) || tooLarge(o) {
+			return
+		}
+		if shownInitially(o) {
+			recent = append(recent, o)
+			return
+		}
+		seedSummaries(o)
+		o = prepareObject(o)
+		cache.set(getKey(o), o)
+	}
+	add := func(objs *unstructured.UnstructuredList) {
+		for i := range objs.Items {
+			addObject(&objs.Items[i])
+		}
+	}
+	opts := listOptions()
+	if *resourceVersion != "" {
+		opts.ResourceVersion = *resourceVersion
+		opts.ResourceVersionMatch = metav1.ResourceVersionMatch(*resourceVersionMatch)
+		if opts.ResourceVersionMatch == "" {
+			opts.ResourceVersionMatch = metav1.ResourceVersionMatchNotOlderThan
+		}
+	}
+	resuming := false
+	if watchCursor != nil {
+		if rv := watchCursor.get(cursorKey(cl, gvr)); rv != "" {
+			// Listing at the stored version makes the cache match the
+			// point the watch resumes from.
+			opts.ResourceVersion = rv
+			opts.ResourceVersionMatch = metav1.ResourceVersionMatchExact
+			resuming = true
+		}
+	}
+	list := func(opts metav1.ListOptions) (string, error) {
+		backoff := newErrorBackoff()
+		for retries := *listRetries; ; retries-- {
+			rv, err := listResource(cl, gvr, opts, add, stopCh)
+			if err == nil || retries <= 0 || !isTransientListError(err) {
+				return rv, err
+			}
+			delay := retryDelay(&backoff, err)
+			klog.V(2).Infof("error listing '%v', retrying in %v: %v", gvr, delay, err)
+			select {
+			case <-stopCh:
+				return rv, err
+			case <-time.After(delay):
+			}
+			cache.clear()
+			recent = nil
+		}
+	}
+	rv, err := list(opts)
+	if err != nil && resuming {
+		klog.Warningf("cannot resume '%v' from resourceVersion %s, listing from scratch: %v", gvr, opts.ResourceVersion, err)
+		cache.clear()
+		recent = nil
+		rv, err = list(listOptions())
+	}
+	switch {
+	case err == nil:
+		if watchCursor != nil {
+			watchCursor.set(cursorKey(cl, gvr), rv)
+		}
+		klog.V(2).Infof("listed %d objects of '%v'", cache.len(), gvr)
+	case failOn(err):
+		klog.Errorf("error listing '%v': %v", gvr, err)
+	case isTransientListError(err):
+		klog.Warningf("error listing '%v', its existing objects will be shown as added: %v", gvr, err)
+	default:
+		klog.V(2).Infof("error listing '%v': %v", gvr, err)
+	}
+	return cache, recent
+}
+
+// isTransientListError reports whether listing may succeed when retried, as
+// opposed to resources that cannot be listed at all or a bad resourceVersion.
+func isTransientListError(err error) bool {
+	return !errors.IsNotFound(err) && !errors.IsForbidden(err) && !errors.IsUnauthorized(err) &&
+		!errors.IsMethodNotSupported(err) && !errors.IsResourceExpired(err) && !errors.IsGone(err) &&
+		!errors.IsBadRequest(err) && !errors.IsInvalid(err)
+}
+
+func spawnWatchers(cl *Cluster, q *listQueue, out chan<- *Event, listed *sync.WaitGroup, stopCh <-chan struct{}) {
+	for {
+		gvr, ok := q.next()
+		if !ok {
+			return
+		}
+		if !memory.waitForMemory(gvr.String(), stopCh) {
+			return
+		}
+		if !accessAllowed(cl, gvr) {
+			q.done(gvr)
+			listed.Done()
+			continue
+		}
+		cache, recent := cacheResource(cl, gvr, stopCh)
+		q.done(gvr)
+		if *dumpInitial {
+			for _, e := range snapshotEvents(cache) {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+		if baselineObjects != nil {
+			for _, e := range baselineEvents(cl, cache) {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+		for _, o := range recent {
+			if e := processEvent(watch.Event{Type: watch.Added, Object: o}, cache); e != nil {
+				e.Cluster = cl.label
+				e.Resource = gvr
+				out <- e
+			}
+		}
+		listed.Done()
+		watchStop := cl.watches.start(gvr, stopCh)
+		goWorker(func() { watchResource(cl, gvr, out, cache, watchStop) })
+	}
+}
+
+func filterResources(resources []*metav1.APIResourceList, in chan<- schema.GroupVersionResource, listed *sync.WaitGroup, groupFilter, gvFilter func(string) bool, gvrFilter func(string, metav1.APIResource) bool, kindFilter func(string) bool, stopCh <-chan struct{}) {
+	defer close(in)
+	first := true
+	for _, g := range resources {
+		if !gvFilter(g.GroupVersion) {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(g.GroupVersion)
+		if err != nil {
+			klog.Error("error parsing GroupVersion: ", err)
+			continue
+		}
+		if !groupFilter(gv.Group) {
+			continue
+		}
+
+		for _, r := range g.APIResources {
+			if !gvrFilter(g.GroupVersion, r) {
+				continue
+			}
+			if !kindFilter(strings.ToLower(r.Kind)) {
+				continue
+			}
+			if len(focusResources) != 0 && !focusResources[schema.GroupResource{Group: gv.Group, Resource: r.Name}] {
+				continue
+			}
+			if defaultExcluded(gv, r) {
+				klog.V(4).Infof("Skipping %s/%s, excluded by default", g.GroupVersion, r.Name)
+				continue
+			}
+
+			if !first && *startupStagger > 0 {
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(wait.Jitter(*startupStagger, 0.5)):
+				}
+			}
+			first = false
+
+			listed.Add(1)
+			select {
+			case <-stopCh:
+				listed.Done()
+				return
+			case in <- schema.GroupVersionResource{Group: gv.Group, Version: gv.Version, Resource: r.Name}:
+			}
+		}
+	}
+}
+
+// printEvents prints events from out to s until stopCh is closed, and a
+// marker once synced is closed. With --ordered-sync, events arriving before
+// then are held back and printed sorted by name ahead of the marker. Key
+// presses in interactive mode filter or pause the output. With
+// --suppress-ephemeral, objects added after then are held back briefly, with
+// --detect-flapping, updates only flipping fields back are dropped, with
+// --trace-gc, deletions are held back too, with --watch-namespaces-lifecycle,
+// namespaces stuck terminating are reported, and with --max-output-rate,
+// events over the rate are dropped or deferred.
+func printEvents(s sinks, out <-chan *Event, synced, stopCh <-chan struct{}) {
+	printed := false
+	var v view
+	var held []*Event
+	limit := newOutputLimiter()
+	reports := limit.reports()
+	objects := newObjectLimit()
+	printAll := func(events []*Event) {
+		for _, e := range events {
+			if !objects.admits(e) {
+				continue
+			}
+			printEvent(s, e)
+			// Events of the initial sync are no changes, which
+			// --first-event-timeout waits for.
+			if !printed && !e.Initial {
+				close(firstPrinted)
+				printed = true
+			}
+		}
+	}
+	emit := func(e *Event) {
+		if !inWindow(e.Timestamp) || !v.shows(e) {
+			return
+		}
+		if v.paused {
+			held = append(held, e)
+			return
+		}
+		printAll(limit.add(e))
+	}
+
+	ephemeral := newEphemeralFilter()
+	bursts := newCoalescer()
+	emitAll := func(events []*Event) {
+		for _, e := range events {
+			emit(e)
+		}
+	}
+	flaps := newFlapDetector()
+	cascades := newGCTracer()
+	stuck := newStuckNamespaces()
+	trace := func(events []*Event) {
+		for _, e := range events {
+			events, warnings := flaps.add(e)
+			for _, msg := range warnings {
+				s.marker(e.Timestamp, msg)
+			}
+			for _, e := range events {
+				emitAll(cascades.add(e))
+			}
+		}
+	}
+	coalesce := func(events []*Event) {
+		for _, e := range events {
+			trace(bursts.add(e))
+		}
+	}
+
+	var pending []*Event
+	emitPending := func() {
+		sort.SliceStable(pending, func(i, j int) bool {
+			return pending[i].FullName() < pending[j].FullName()
+		})
+		for _, e := range pending {
+			emit(e)
+		}
+		pending = nil
+	}
+	emitHeld := func() {
+		for _, e := range held {
+			emit(e)
+		}
+		held = nil
+	}
+	receive := func(e *Event) {
+		stuck.observe(e)
+		if synced != nil {
+			e.Initial = true
+			if *orderedSync {
+				pending = append(pending, e)
+			} else {
+				emit(e)
+			}
+			return
+		}
+		coalesce(ephemeral.add(e))
+	}
+	for {
+		select {
+		case <-stopCh:
+			v.paused = false
+			emitHeld()
+			// Events still queued go through the same filters as the
+			// others before every queue is flushed.
+			for drained := false; !drained; {
+				select {
+				case e := <-out:
+					receive(e)
+				default:
+					drained = true
+				}
+			}
+			emitPending()
+			coalesce(ephemeral.flush())
+			trace(bursts.flush())
+			emitAll(cascades.flush())
+			printAll(limit.flush())
+			return
+		case k := <-keyPresses:
+			if v.toggle(k) && !v.paused {
+				emitHeld()
+			}
+		case <-synced:
+			emitPending()
+			s.marker(Now(), "initial sync complete")
+			synced = nil
+		case <-ephemeral.timer():
+			coalesce(ephemeral.due())
+		case <-bursts.timer():
+			trace(bursts.due())
+		case <-cascades.timer():
+			emitAll(cascades.due())
+		case <-stuck.timer():
+			emitAll(stuck.due())
+		case <-limit.timer():
+			printAll(limit.due())
+		case <-reports:
+			if msg := limit.report(); msg != "" {
+				s.marker(Now(), msg)
+			}
+		case e := <-out:
+			receive(e)
+		}
+	}
+}
+
+func printEvent(s sinks, e *Event) {
+	eventSeq++
+	e.Seq = eventSeq
+	s.event(e)
+	if changeCounts != nil {
+		changeCounts.record(e)
+	}
+	if deleteNotifier != nil && e.Type == watch.Deleted {
+		deleteNotifier.notify(e)
+	}
+	if eventEmitters != nil {
+		eventEmitters[e.Cluster].emit(e)
+	}
+	if eventCommand != nil {
+		eventCommand.run(e)
+	}
+	if spanExporter != nil {
+		spanExporter.export(e)
+	}
+	if eventUI != nil {
+		eventUI.publish(e)
+	}
+}
+
+// stop initiates a graceful shutdown after which the process exits with code.
+func stop(code int) {
+	stopOnce.Do(func() {
+		exitCode = code
+		close(stopChan)
+	})
+}
+
+// outputWidth returns the column limit for the default formats: --max-width if
+// set, else the terminal width when printing to one.
+func outputWidth() int {
+	if *maxWidth != 0 || *outFile != "" {
+		return *maxWidth
+	}
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return max(w, minWidth)
+	}
+	return 0
+}
+
+func watchFirstEvent(synced <-chan struct{}, timeout time.Duration, stopCh <-chan struct{}) {
+	select {
+	case <-stopCh:
+		return
+	case <-synced:
+	}
+	select {
+	case <-stopCh:
+	case <-firstPrinted:
+	case <-time.After(timeout):
+		klog.Errorf("no events within %v of the initial sync", timeout)
+		stop(1)
+	}
+}
+
+// Main parses the command line, watches the clusters it selects until
+// interrupted and exits.
+func Main() {
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	pflag.CommandLine.AddGoFlagSet(klogFlags)
+	pflag.Lookup("raw").NoOptDefVal = "compact"
+	pflag.Lookup("cluster-label").NoOptDefVal = contextLabel
+	pflag.Parse()
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			klog.Fatal("error loading config: ", err)
+		}
+	}
+
+	if *logFormat != "" {
+		if err := logging.SetupKlog(os.Stderr, *logFormat); err != nil {
+			klog.Fatal("error configuring logging: ", err)
+		}
+	}
+
+	formatter, err := Configure()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	if len(*diffCaptures) != 0 {
+		os.Exit(compareCaptures(sinks{{Writer: os.Stdout, Formatter: formatter}}))
+	}
+
+	if err := readStdinKubeconfig(*kubeconfigs); err != nil {
+		klog.Fatal(err)
+	}
+	specs := clusterSpecs(*kubeconfigs, *contexts)
+	if len(specs) > 1 && *clusterLabel != "" && *clusterLabel != contextLabel {
+		klog.Fatal("--cluster-label cannot name more than one cluster")
+	}
+	var clusters []*Cluster
+	for _, spec := range specs {
+		cl, err := newCluster(spec)
+		if err != nil {
+			klog.Fatal(err)
+		}
+		clusters = append(clusters, cl)
+	}
+	labelClusters(clusters, specs)
+
+	var w io.Writer = os.Stdout
+	var f io.WriteCloser
+	if *outFile != "" {
+		f, err = openOutputFile(*outFile)
+		if err != nil {
+			klog.Fatal("error opening output file: ", err)
+		}
+		w = f
+	}
+
+	outputs, closers, err := openSinks()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	outputs = append([]Sink{{Writer: w, Formatter: formatter}}, outputs...)
+
+	restoreTerminal := func() {}
+	if *interactive {
+		restoreTerminal = startKeys()
+	}
+
+	code := Run(clusters, outputs, signals.SetupSignalHandler())
+
+	if f != nil {
+		if err := f.Close(); err != nil {
+			klog.Error("error closing output file: ", err)
+		}
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			klog.Error("error closing output: ", err)
+		}
+	}
+	restoreTerminal()
+	os.Exit(code)
+}
+
+// Configure validates the flags and prepares the filters and redactions they
+// describe. It returns the formatter selected by the flags.
+func Configure() (EventFormatter, error) {
+	if *maxWidth != 0 && *maxWidth < minWidth {
+		return nil, fmt.Errorf("--max-width must be 0 or at least %d, got %d", minWidth, *maxWidth)
+	}
+	if *listConcurrency < 1 {
+		return nil, fmt.Errorf("--list-concurrency must be at least 1")
+	}
+	if *resume && *cursorFile == "" {
+		return nil, fmt.Errorf("--resume requires --cursor-file")
+	}
+	switch metav1.ResourceVersionMatch(*resourceVersionMatch) {
+	case "", metav1.ResourceVersionMatchNotOlderThan, metav1.ResourceVersionMatchExact:
+		if *resourceVersionMatch != "" && *resourceVersion == "" {
+			return nil, fmt.Errorf("--resource-version-match requires --resource-version")
+		}
+	default:
+		return nil, fmt.Errorf("unknown resourceVersion match %q", *resourceVersionMatch)
+	}
+	switch *backend {
+	case "watch":
+	case "informer":
+		if *cursorFile != "" || *resyncInterval > 0 || *pollInterval > 0 || *resourceVersion != "" || *metadataOnly || *baselineFile != "" || *discoveryRefresh > 0 {
+			return nil, fmt.Errorf("--cursor-file, --resync-interval, --poll-interval, --resource-version, --metadata-only, --baseline and --discovery-refresh are not supported with the informer backend")
+		}
+	default:
+		return nil, fmt.Errorf("unknown backend %q", *backend)
+	}
+	switch *diffAgainst {
+	case "previous", "first":
+	default:
+		return nil, fmt.Errorf("unknown --diff-against %q", *diffAgainst)
+	}
+	if *lowMemory && (*backend == "informer" || *diffAgainst == "first") {
+		return nil, fmt.Errorf("--low-memory is not supported with the informer backend or --diff-against first")
+	}
+	if *memoryLimit > 0 && (*backend == "informer" || *diffAgainst == "first") {
+		return nil, fmt.Errorf("--memory-limit is not supported with the informer backend or --diff-against first")
+	}
+	switch *rawOutput {
+	case "", "compact", "pretty":
+	default:
+		return nil, fmt.Errorf("unknown raw format %q", *rawOutput)
+	}
+	for _, validate := range []func() error{validateObjectFormat, validateContentType, parseWindow, parseSample, validateChatty, parseColors, validateExcludeNames, parsePredicates, validateOutputRate, validateExitOnStdinClose, validateDiffStyle, validateDiffCaptures, validateUnknownEvents, loadBaseline} {
+		if err := validate(); err != nil {
+			return nil, err
+		}
+	}
+	if *specOnly && *labelsAnnotationsOnly {
+		return nil, fmt.Errorf("only one of --spec-only and --labels-annotations-only can be given")
+	}
+	if *specOnly && *metadataOnly {
+		return nil, fmt.Errorf("--spec-only cannot be used with --metadata-only, which fetches no spec")
+	}
+	enableFocusModes()
+	if err := compileRedactions(); err != nil {
+		return nil, fmt.Errorf("error parsing redact regex: %v", err)
+	}
+	compileIgnoredFields()
+	if err := parseArrayKeys(); err != nil {
+		return nil, fmt.Errorf("error parsing array keys: %v", err)
+	}
+	var err error
+	if namespaceFilter, err = newNamespaceFilter(*namespaces); err != nil {
+		return nil, err
+	}
+	if *excludeSystemNamespaces {
+		namespaceFilter = excludeSystem(namespaceFilter, *systemNamespacePrefixes)
+	}
+	if *maxNamespaces > 0 && len(*namespaces) == 0 && len(*namespaceRegexes) == 0 {
+		namespaceFilter = limitNamespaces(namespaceFilter, *maxNamespaces)
+	}
+	groups := make([]string, len(*apiGroups))
+	for i, g := range *apiGroups {
+		if n := countPrefix(g, '!'); g[n:] == "core" {
+			g = g[:n]
+		}
+		groups[i] = g
+	}
+	groupFilter = NewFilter(groups)
+	gvFilter = NewFilter(*groupVersions)
+	gvrFilter = NewResourceFilter(*groupVersionResources)
+	kinds := make([]string, len(*onlyKinds))
+	for i, k := range *onlyKinds {
+		kinds[i] = strings.ToLower(k)
+	}
+	kindFilter = NewFilter(kinds)
+
+	switch {
+	case *templateText != "" && *templateFile != "":
+		return nil, fmt.Errorf("only one of --template and --template-file can be given")
+	case *templateText != "" || *templateFile != "":
+		if *outFormat != "" {
+			return nil, fmt.Errorf("--template cannot be combined with --out")
+		}
+		tmpl, err := parseTemplate(*colorize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template: %v", err)
+		}
+		return &TemplateFormatter{Template: tmpl}, nil
+	case *outFormat == "trace" || *outFormat == "json" || *outFormat == "audit":
+		if *statusToStderr {
+			return nil, fmt.Errorf("--status-to-stderr is not supported with %s output", *outFormat)
+		}
+		*colorize = false
+	case *outFormat == "ndjson" || *outFormat == "delta" || *outFormat == "kubectl-diff":
+		*colorize = false
+	}
+	return formatterFor(*outFormat, outputWidth(), *colorize)
+}
+
+// headerEpoch returns the time event headers count from with
+// --relative-time, the start of the watch as formatters are created ahead of
+// it, else the zero time.
+func headerEpoch() time.Time {
+	if !*relativeTime {
+		return time.Time{}
+	}
+	return Now()
+}
+
+// formatterFor returns the formatter of an output format. width limits the
+// default formats and color says whether they may be colored.
+func formatterFor(format string, width int, color bool) (EventFormatter, error) {
+	switch format {
+	case "", "default":
+		return &DefaultFormatter{MaxWidth: width, ShowSeq: *showSeq, ShowResourceVersion: *showResourceVersion, NoColor: !color, Since: headerEpoch(), Columns: newColumnLayout(), Labels: *showLabels, ShowNode: *annotateNode}, nil
+	case "side-by-side":
+		return &SideBySideFormatter{DefaultFormatter: DefaultFormatter{MaxWidth: width, ShowSeq: *showSeq, ShowResourceVersion: *showResourceVersion, NoColor: !color, Since: headerEpoch(), Columns: newColumnLayout(), Labels: *showLabels, ShowNode: *annotateNode}, Color: color}, nil
+	case "trace":
+		return &TraceEventFormatter{Compact: *compactJSON}, nil
+	case "json":
+		return &JSONFormatter{Array: true, Indent: *jsonPretty}, nil
+	case "ndjson":
+		return &JSONFormatter{Indent: *jsonPretty}, nil
+	case "delta":
+		return &DeltaFormatter{JSONFormatter{Indent: *jsonPretty}}, nil
+	case "audit":
+		return &AuditFormatter{Indent: *jsonPretty}, nil
+	case "kubectl-diff":
+		return &KubectlDiffFormatter{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// resetRun clears the state left behind by a previous Run.
+func resetRun() {
+	stopOnce = sync.Once{}
+	stopChan = make(chan struct{})
+	exitCode = 0
+	firstPrinted = make(chan struct{})
+	eventSeq = 0
+	memory, watchCursor, watchHealth, changeCounts = nil, nil, nil, nil
+	deleteNotifier, eventCommand, spanExporter, eventUI = nil, nil, nil, nil
+	eventEmitters = nil
+
+	cacheStatsMu.Lock()
+	cacheStats = map[string]*cacheStat{}
+	cacheStatsMu.Unlock()
+	deniedMu.Lock()
+	denied = map[string][]string{}
+	deniedMu.Unlock()
+	serviceEndpoints.mu.Lock()
+	serviceEndpoints.slices = map[string]map[string]map[string]bool{}
+	serviceEndpoints.mu.Unlock()
+}
+
+// waitWorkers waits for the workers of a stopped Run, discarding the events
+// they still send to out.
+func waitWorkers(out <-chan *Event) {
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	for {
+		select {
+		case <-out:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// NewCluster returns a cluster watched through the given clients, e.g. fakes
+// in tests. Its events are tagged with label unless it is empty.
+func NewCluster(label string, dc dynamic.Interface, disc discovery.DiscoveryInterface) *Cluster {
+	return &Cluster{name: label, label: label, dc: dc, disc: disc}
+}
+
+// Run watches clusters and writes their events to every one of outputs until
+// interrupt is closed or the flags ask to stop. It must be called after
+// Configure, one run at a time, and returns the exit code of the process.
+func Run(clusters []*Cluster, outputs []Sink, interrupt <-chan struct{}) int {
+	resetRun()
+	startTime = Now().Truncate(time.Second)
+	stopCh := (<-chan struct{})(stopChan)
+	out := make(chan *Event, 100)
+	// Stopping only matters when returning early on an error.
+	defer func() {
+		stop(1)
+		waitWorkers(out)
+	}()
+	goWorker(func() {
+		select {
+		case <-interrupt:
+			stop(0)
+		case <-stopCh:
+		}
+	})
+	if !stopAt.IsZero() {
+		goWorker(func() { stopAtDeadline(stopCh) })
+	}
+	if *exitOnStdinClose {
+		goWorker(func() { stopOnStdinClose(stopCh) })
+	}
+	if *memoryLimit > 0 {
+		memory = newMemoryMonitor(*memoryLimit)
+		goWorker(func() { memory.run(stopCh) })
+	}
+	if *printCacheStats > 0 {
+		goWorker(func() { reportCacheStats(os.Stderr, *printCacheStats, stopCh) })
+	}
+	if *cursorFile != "" {
+		watchCursor = &cursor{versions: map[string]string{}}
+		if *resume {
+			var err error
+			if watchCursor, err = loadCursor(*cursorFile); err != nil {
+				klog.Error("error reading cursor file: ", err)
+				return 1
+			}
+		}
+		goWorker(func() { watchCursor.run(*cursorFile, *cursorInterval, stopCh) })
+	}
+	if *healthAddr != "" || *reportFile != "" {
+		watchHealth = &healthRegistry{watchers: map[string]*watcherHealth{}}
+	}
+	if *healthAddr != "" {
+		if err := watchHealth.serve(*healthAddr, stopCh); err != nil {
+			klog.Error("error serving health: ", err)
+			return 1
+		}
+	}
+	if *notifyDeletesWebhook != "" {
+		deleteNotifier = newWebhookNotifier(*notifyDeletesWebhook, *notifyInterval)
+		go deleteNotifier.run()
+	}
+	if *emitEvents {
+		startEventEmitters(clusters, stopCh)
+	}
+	if *execCommand != "" {
+		f, err := formatterFor(*execFormat, 0, false)
+		if err != nil {
+			klog.Error(err)
+			return 1
+		}
+		eventCommand = newCommandRunner(*execCommand, f, max(*execConcurrency, 1))
+	}
+	if *otelEndpoint != "" {
+		spanExporter = newOtelExporter(*otelEndpoint)
+	}
+	if *uiAddr != "" {
+		var err error
+		if eventUI, err = newUIServer(*uiAddr, stopCh); err != nil {
+			klog.Error("error serving UI: ", err)
+			return 1
+		}
+	}
+	if err := resolveSince(clusters); err != nil {
+		klog.Error(err)
+		return 1
+	}
+	var dispatched, listed sync.WaitGroup
+	for _, cl := range clusters {
+		resources, err := cl.disc.ServerPreferredResources()
+		if failed, ok := err.(*discovery.ErrGroupDiscoveryFailed); ok {
+			for gv, err := range failed.Groups {
+				klog.Warningf("skipping %v of cluster %s, discovery failed: %v", gv, cl.name, err)
+			}
+		} else if err != nil {
+			klog.Errorf("error getting resources of cluster %s: %v", cl.name, err)
+			return 1
+		}
+
+		if *discoveryRefresh > 0 {
+			cl.watches = newWatchSet()
+			goWorker(func() { refreshDiscovery(cl, *discoveryRefresh, out, stopCh) })
+		}
+		in := make(chan schema.GroupVersionResource, *listConcurrency)
+		q := newListQueue(in, *listConcurrency, stopCh)
+		for i := 0; i < *listConcurrency; i++ {
+			if *backend == "informer" {
+				goWorker(func() { spawnInformers(cl, q, out, &listed, stopCh) })
+			} else {
+				goWorker(func() { spawnWatchers(cl, q, out, &listed, stopCh) })
+			}
+		}
+		dispatched.Add(1)
+		go func() {
+			defer dispatched.Done()
+			filterResources(resources, in, &listed, groupFilter, gvFilter, gvrFilter, kindFilter, stopCh)
+		}()
+	}
+
+	synced := make(chan struct{})
+	go func() {
+		dispatched.Wait()
+		listed.Wait()
+		reportDenied()
+		close(synced)
+	}()
+	if *firstEventTimeout > 0 {
+		goWorker(func() { watchFirstEvent(synced, *firstEventTimeout, stopCh) })
+	}
+
+	if *topN > 0 || *reportFile != "" {
+		changeCounts = newChangeCounter()
+	}
+
+	s := make(sinks, len(outputs))
+	for i, o := range outputs {
+		s[i] = Sink{Writer: &pipeWriter{w: o.Writer}, Formatter: o.Formatter}
+	}
+	s.preamble()
+	s.legend()
+	printEvents(s, out, synced, stopCh)
+	s.epilogue()
+	waitWorkers(out)
+	if eventCommand != nil {
+		eventCommand.wait()
+	}
+	if deleteNotifier != nil {
+		deleteNotifier.close()
+	}
+	if spanExporter != nil {
+		spanExporter.Close()
+	}
+	if changeCounts != nil && *topN > 0 {
+		changeCounts.write(os.Stderr, *topN)
+	}
+	if *reportFile != "" {
+		if err := writeReport(*reportFile, changeCounts, exitCode); err != nil {
+			klog.Error("error writing report file: ", err)
+		}
+	}
+
+	if watchCursor != nil {
+		if err := watchCursor.save(*cursorFile); err != nil {
+			klog.Error("error writing cursor file: ", err)
+		}
+	}
+	return exitCode
+}
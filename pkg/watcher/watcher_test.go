@@ -0,0 +1,130 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var configMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// fakeDiscovery serves the resources of its Fake, which FakeDiscovery leaves
+// out of the preferred resources.
+type fakeDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d fakeDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+// syncBuffer is a bytes.Buffer that Run can write to while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newFakeCluster(objects ...runtime.Object) (*Cluster, *dynamicfake.FakeDynamicClient) {
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{configMapsResource: "ConfigMapList"}, objects...)
+	disc := fakeDiscovery{&fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: []string{"list", "watch"}}},
+	}}}}}
+	return NewCluster("", dc, disc), dc
+}
+
+// waitFor polls out until it contains text.
+func waitFor(t *testing.T, out *syncBuffer, text string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !strings.Contains(out.String(), text); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q in output:\n%s", text, out.String())
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	formatter, err := Configure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := configMap("initial")
+	cm.SetNamespace("default")
+	// Run again to check that it does not depend on the state the first run
+	// left behind.
+	for run := 0; run < 2; run++ {
+		cl, dc := newFakeCluster(cm.DeepCopy())
+		out := &syncBuffer{}
+		interrupt := make(chan struct{})
+		code := make(chan int)
+		go func() {
+			code <- Run([]*Cluster{cl}, []Sink{{Writer: out, Formatter: formatter}}, interrupt)
+		}()
+		waitFor(t, out, "initial sync complete")
+
+		// The watch starts after the initial sync, so changes are
+		// repeated until one is seen.
+		client := dc.Resource(configMapsResource).Namespace("default")
+		for i := 0; !strings.Contains(out.String(), "changed"); i++ {
+			if i == 1000 {
+				t.Fatalf("run %d: no change was printed:\n%s", run, out.String())
+			}
+			cm := configMap(fmt.Sprintf("changed %d", i))
+			cm.SetNamespace("default")
+			if _, err := client.Update(context.Background(), cm, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(interrupt)
+		if c := <-code; c != 0 {
+			t.Errorf("run %d: exit code %d, want 0", run, c)
+		}
+
+		got := out.String()
+		for _, want := range []string{"default/config", `"key": "initial"`, `"key": "changed`} {
+			if !strings.Contains(got, want) {
+				t.Errorf("run %d: expected %q in output:\n%s", run, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+var wherePredicates = pflag.StringArray("where", nil, "Only show events of objects for which a JSONPath expression yields the given value, e.g. '{.status.phase}=Failed'. Can be given multiple times, all of which must match")
+
+type predicate struct {
+	expr  string
+	path  *jsonpath.JSONPath
+	value string
+}
+
+var predicates []predicate
+
+func parsePredicates() error {
+	predicates = nil
+	for _, p := range *wherePredicates {
+		i := strings.LastIndexByte(p, '=')
+		if i <= 0 {
+			return fmt.Errorf("invalid --where %q, expected <jsonpath>=<value>", p)
+		}
+		expr := p[:i]
+		path := jsonpath.New("where").AllowMissingKeys(true)
+		if err := path.Parse(expr); err != nil {
+			return fmt.Errorf("invalid --where %q: %v", p, err)
+		}
+		predicates = append(predicates, predicate{expr, path, p[i+1:]})
+	}
+	return nil
+}
+
+// matchesWhere reports whether o satisfies all --where predicates. A
+// predicate matches when any of the values its expression yields equals the
+// expected one.
+func matchesWhere(o *unstructured.Unstructured) bool {
+	for _, p := range predicates {
+		results, err := p.path.FindResults(o.Object)
+		if err != nil {
+			klog.V(2).Infof("error evaluating %s on %s: %v", p.expr, o.GetName(), err)
+			return false
+		}
+		if !anyEquals(results, p.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyEquals(results [][]reflect.Value, value string) bool {
+	for _, r := range results {
+		for _, v := range r {
+			if v.IsValid() && v.CanInterface() && fmt.Sprint(v.Interface()) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
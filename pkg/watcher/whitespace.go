@@ -0,0 +1,51 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var ignoreWhitespace = pflag.Bool("ignore-whitespace", false, "Ignore line endings and trailing whitespace of string values, e.g. configuration embedded in ConfigMaps")
+
+// normalizeWhitespace rewrites every string in v with CRLF line endings
+// turned into LF and trailing whitespace removed from each line, so that
+// values differing only in those compare equal.
+func normalizeWhitespace(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeWhitespace(item)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeWhitespace(value)
+		}
+	case string:
+		if !strings.ContainsAny(v, " \t\r\n") {
+			return v
+		}
+		lines := strings.Split(strings.ReplaceAll(v, "\r\n", "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t\r")
+		}
+		return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	}
+	return v
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	startAtFlag = pflag.String("start-at", "", "Only print events from this time on, as RFC3339 or a duration from now such as 10m")
+	stopAtFlag  = pflag.String("stop-at", "", "Exit at this time, as RFC3339 or a duration from now such as 1h")
+
+	startAt, stopAt time.Time
+)
+
+// parseWindowTime parses s as an RFC3339 time or a duration relative to now.
+// An empty s yields the zero time.
+func parseWindowTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(strings.TrimPrefix(s, "+"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 time nor a duration", s)
+	}
+	return now.Add(d), nil
+}
+
+func parseWindow() error {
+	now := time.Now()
+	var err error
+	if startAt, err = parseWindowTime(*startAtFlag, now); err != nil {
+		return err
+	}
+	if stopAt, err = parseWindowTime(*stopAtFlag, now); err != nil {
+		return err
+	}
+	if !startAt.IsZero() && !stopAt.IsZero() && !stopAt.After(startAt) {
+		return fmt.Errorf("--stop-at must be after --start-at")
+	}
+	return nil
+}
+
+// inWindow reports whether events at t fall between --start-at and --stop-at.
+func inWindow(t time.Time) bool {
+	return (startAt.IsZero() || !t.Before(startAt)) && (stopAt.IsZero() || t.Before(stopAt))
+}
+
+// stopAtDeadline shuts down once --stop-at is reached.
+func stopAtDeadline(stopCh <-chan struct{}) {
+	select {
+	case <-stopCh:
+	case <-time.After(time.Until(stopAt)):
+		stop(0)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const appsGroup = "apps"
+
+// summarizePod reports the phase of a Pod, the node it is scheduled on,
+// its ready containers and container restarts, e.g. "ready 1/2 -> 2/2" and
+// "container app restarted (3 restarts, last OOMKilled)".
+func summarizePod(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: phase %s, ready %s", orNone(podPhase(new)), podReady(new))
+	case len(new.Object) == 0:
+		return fmt.Sprintf("deleted: phase %s, ready %s", orNone(podPhase(old)), podReady(old))
+	}
+	var lines []string
+	if before, after := podPhase(old), podPhase(new); before != after {
+		lines = append(lines, fmt.Sprintf("phase %s -> %s", orNone(before), orNone(after)))
+	}
+	before, _, _ := unstructured.NestedString(old.Object, "spec", "nodeName")
+	if after, _, _ := unstructured.NestedString(new.Object, "spec", "nodeName"); after != before && after != "" {
+		lines = append(lines, "scheduled on "+after)
+	}
+	if before, after := podReady(old), podReady(new); before != after {
+		lines = append(lines, fmt.Sprintf("ready %s -> %s", before, after))
+	}
+	for _, r := range containerRestarts(old, new) {
+		line := fmt.Sprintf("container %s restarted (%d restarts", r.container, r.after)
+		if r.reason != "" {
+			line += ", last " + r.reason
+		}
+		lines = append(lines, line+")")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// summarizePodRestarts reports the containers of a Pod that restarted along
+// with why they last terminated, e.g. "pod/app: restarts 2 -> 3 (reason:
+// OOMKilled, exit 137)", and drops all other changes.
+func summarizePodRestarts(old, new *unstructured.Unstructured) string {
+	if len(old.Object) == 0 || len(new.Object) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, r := range containerRestarts(old, new) {
+		line := fmt.Sprintf("%s/%s: restarts %d -> %d", new.GetName(), r.container, r.before, r.after)
+		var details []string
+		if r.reason != "" {
+			details = append(details, "reason: "+r.reason)
+		}
+		if r.exited {
+			details = append(details, fmt.Sprintf("exit %d", r.exitCode))
+		}
+		if len(details) > 0 {
+			line += " (" + strings.Join(details, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A containerRestart describes a container whose restartCount went up, with
+// the reason and exit code of its last termination if known.
+type containerRestart struct {
+	container     string
+	before, after int64
+	reason        string
+	exitCode      int64
+	exited        bool
+}
+
+// containerRestarts returns the init and regular containers of a Pod whose
+// restartCount is higher in new than in old.
+func containerRestarts(old, new *unstructured.Unstructured) []containerRestart {
+	var restarts []containerRestart
+	for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+		counts := map[string]int64{}
+		for _, s := range containerStatuses(old, field) {
+			name, _, _ := unstructured.NestedString(s, "name")
+			counts[name], _, _ = unstructured.NestedInt64(s, "restartCount")
+		}
+		for _, s := range containerStatuses(new, field) {
+			name, _, _ := unstructured.NestedString(s, "name")
+			n, _, _ := unstructured.NestedInt64(s, "restartCount")
+			if n <= counts[name] {
+				continue
+			}
+			r := containerRestart{container: name, before: counts[name], after: n}
+			r.reason, _, _ = unstructured.NestedString(s, "lastState", "terminated", "reason")
+			r.exitCode, r.exited, _ = unstructured.NestedInt64(s, "lastState", "terminated", "exitCode")
+			restarts = append(restarts, r)
+		}
+	}
+	return restarts
+}
+
+func podPhase(o *unstructured.Unstructured) string {
+	phase, _, _ := unstructured.NestedString(o.Object, "status", "phase")
+	return phase
+}
+
+// podReady returns the number of ready containers of a Pod out of those in
+// its spec.
+func podReady(o *unstructured.Unstructured) string {
+	containers, _, _ := unstructured.NestedSlice(o.Object, "spec", "containers")
+	ready := 0
+	for _, s := range containerStatuses(o, "containerStatuses") {
+		if ok, _, _ := unstructured.NestedBool(s, "ready"); ok {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(containers))
+}
+
+// containerStatuses returns the container statuses in field of the status of
+// a Pod: containerStatuses or initContainerStatuses.
+func containerStatuses(o *unstructured.Unstructured, field string) []map[string]interface{} {
+	list, _, _ := unstructured.NestedSlice(o.Object, "status", field)
+	var statuses []map[string]interface{}
+	for _, s := range list {
+		if m, ok := s.(map[string]interface{}); ok {
+			statuses = append(statuses, m)
+		}
+	}
+	return statuses
+}
+
+// summarizeDeployment reports the desired replicas and container images of
+// a Deployment, the progress of its rollouts, e.g. "updated 1/3 -> 3/3", and
+// the transitions of its conditions.
+func summarizeDeployment(old, new *unstructured.Unstructured) string {
+	switch {
+	case len(old.Object) == 0:
+		return fmt.Sprintf("created: replicas %s, images %s", hpaInt(new, "spec", "replicas"), orNone(strings.Join(deploymentImages(new), ",")))
+	case len(new.Object) == 0:
+		return "deleted: replicas " + hpaInt(old, "spec", "replicas")
+	}
+	var lines []string
+	if before, after := hpaInt(old, "spec", "replicas"), hpaInt(new, "spec", "replicas"); before != after {
+		lines = append(lines, fmt.Sprintf("replicas %s -> %s", before, after))
+	}
+	if before, after := strings.Join(deploymentImages(old), ","), strings.Join(deploymentImages(new), ","); before != after {
+		lines = append(lines, fmt.Sprintf("images %s -> %s", orNone(before), orNone(after)))
+	}
+	for _, field := range []string{"updatedReplicas", "readyReplicas", "availableReplicas"} {
+		before := jobCount(old, "status", field) + "/" + jobCount(old, "status", "replicas")
+		after := jobCount(new, "status", field) + "/" + jobCount(new, "status", "replicas")
+		if before != after {
+			lines = append(lines, fmt.Sprintf("%s %s -> %s", strings.TrimSuffix(field, "Replicas"), before, after))
+		}
+	}
+	lines = append(lines, conditionTransitions(old, new)...)
+	return strings.Join(lines, "\n")
+}
+
+// deploymentImages returns the images of the containers of a Deployment as
+// container=image.
+func deploymentImages(o *unstructured.Unstructured) []string {
+	containers, _, _ := unstructured.NestedSlice(o.Object, "spec", "template", "spec", "containers")
+	var images []string
+	for _, c := range containers {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(m, "name")
+		image, _, _ := unstructured.NestedString(m, "image")
+		images = append(images, name+"="+image)
+	}
+	return images
+}
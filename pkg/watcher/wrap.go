@@ -0,0 +1,122 @@
+/*
+Copyright 2019 VMware, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+)
+
+const ellipsis = "…"
+
+// minWidth is the narrowest --max-width, which leaves continuation lines of
+// wrapped diffs room for their marker, some indentation and some text.
+const minWidth = 10
+
+// truncateMiddle shortens s to at most width runes by replacing its middle
+// with an ellipsis.
+func truncateMiddle(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width == 1 {
+		return ellipsis
+	}
+	head := (width - 1) / 2
+	tail := width - 1 - head
+	return string(r[:head]) + ellipsis + string(r[len(r)-tail:])
+}
+
+// wrapLines wraps every line of a diff to width runes. Continuation lines keep
+// the diff marker and are indented past the original line's indentation. The
+// further lines of multi-line strings have no marker to keep.
+func wrapLines(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	// The color of a multi-line string spans its lines.
+	open := ""
+	for i, line := range lines {
+		start, body, end := splitColor(line)
+		marked := open == "" && hasMarker(body)
+		if start == "" {
+			start = open
+		}
+		lines[i] = wrapLine(line, start, body, end, marked, width)
+		open = start
+		if end != "" {
+			open = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hasMarker reports whether line starts with the marker of a diff line,
+// followed by the gutter.
+func hasMarker(line string) bool {
+	return len(line) > 1 && strings.IndexByte("+- ", line[0]) >= 0 && line[1] == ' '
+}
+
+func wrapLine(line, start, body, end string, marked bool, width int) string {
+	r := []rune(body)
+	if len(r) <= width {
+		return line
+	}
+
+	indent := 0
+	if marked {
+		indent = 1
+	}
+	for indent < len(r) && r[indent] == ' ' {
+		indent++
+	}
+	indent += 2
+	if indent > width/2 {
+		indent = max(width/2, 1)
+	}
+	prefix := strings.Repeat(" ", indent)
+	if marked {
+		prefix = string(r[0]) + prefix[1:]
+	}
+
+	var buf strings.Builder
+	buf.WriteString(start + string(r[:width]) + end)
+	for r = r[width:]; len(r) > 0; {
+		n := width - indent
+		if n > len(r) {
+			n = len(r)
+		}
+		buf.WriteString("\n" + start + prefix + string(r[:n]) + end)
+		r = r[n:]
+	}
+	return buf.String()
+}
+
+// splitColor separates the ANSI color sequences the ascii diff formatter wraps
+// around colored lines from the visible text. Those around multi-line strings
+// start on the first line and end on the last.
+func splitColor(line string) (start, body, end string) {
+	const reset = "\x1b[0m"
+	if loc := sgrSequence.FindStringIndex(line); loc != nil && loc[0] == 0 && line[:loc[1]] != reset {
+		start, line = line[:loc[1]], line[loc[1]:]
+	}
+	if strings.HasSuffix(line, reset) {
+		line, end = line[:len(line)-len(reset)], reset
+	}
+	return start, line, end
+}